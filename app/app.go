@@ -1,14 +1,13 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"math"
 	"strings"
+	"time"
 )
 
-var evaluationsPerSearch = 0
-
 // InProgress denotes if game is in progress
 const InProgress = 0
 
@@ -21,54 +20,192 @@ const Lost = 2
 // Stalemate denotes if game is a stalemate
 const Stalemate = 3
 
-// GameState represents status of active game
-type GameState struct {
+// Repetition denotes a draw as the same position occurred three times
+const Repetition = 4
+
+// FivefoldRepetition denotes a draw as the same position occurred five
+// times. Unlike threefold repetition it need not be claimed.
+const FivefoldRepetition = 5
+
+// FiftyMoves denotes a draw as each side made fifty moves without a capture
+// or pawn move
+const FiftyMoves = 6
+
+// SeventyFiveMoves denotes a draw as each side made seventy five moves
+// without a capture or pawn move. Unlike the fifty move rule it need not be
+// claimed.
+const SeventyFiveMoves = 7
+
+// InsufficientMaterial denotes a draw as neither side can checkmate
+const InsufficientMaterial = 8
+
+// Game holds the state of a game. Games are independent of each other, so
+// any number of them can be played or searched at once.
+type Game struct {
 	board           *boardConfig
 	myColor         int
 	myPieces        map[int][]*piece
 	otherPieces     map[int][]*piece
 	materialBalance int
 	moveCount       int
+	turn            int          // Color of the side to move
+	halfmoveClock   int          // Half moves since last capture or pawn move
+	castling        int          // Castling rights still available
+	states          []moveState  // State prior to each move, used to undo
+	startPly        int          // Half moves played before starting position
+	startFEN        string       // Starting position unless initial position
+	started         time.Time    // Time at which game was set up
+	history         []playedMove // Moves played so far
+
+	// Engine search
+	maxDepth    int                 // Plies searched by the engine
+	nodes       int                 // Board states evaluated in current search
+	qnodes      int                 // Part of nodes in quiescence search
+	tt          *TranspositionTable // Outcome of searched positions
+	searchDepth int                 // Depth of the current iteration
+	pv          [][]boardMove       // Principal variation found at each ply
+	prevPV      []boardMove         // Principal variation of last iteration
+	followPV    bool                // Search is following prevPV
+	onIteration func(SearchInfo)    // Reports each completed iteration
+	pruning     Pruning             // Selective search techniques in use
+	threads     int                 // Threads searching at once
+	thread      int                 // Number of this thread, 0 for main
+	counts      []nodeCount         // Nodes searched by each thread
+
+	// Move ordering, kept through the iterations of a search
+	searchPath    []boardMove        // Moves made to reach each ply
+	killers       [][2]boardMove     // Quiet moves cutting off at each ply
+	counterMoves  *[64][64]boardMove // Refutation by from and to square
+	historyScores *[3][64][64]int    // Cutoffs by color, from and to square
+
+	// Search limits
+	limits         SearchLimits    // Limits of the current search
+	searchStart    time.Time       // Time at which the current search started
+	optimumTime    time.Duration   // Time the search aims to finish within
+	maxTime        time.Duration   // Time after which the search is aborted
+	nextClockCheck int             // Node count at which to look at the clock
+	stop           <-chan struct{} // Closed when the search is to stop
+	aborted        bool            // Search ran out of time or nodes
+}
+
+// moveState holds the parts of game state which cannot be recovered from
+// a move when undoing it
+type moveState struct {
+	turn          int
+	halfmoveClock int
+	castling      int
+	key           uint64 // Zobrist key, used to detect repetitions
+}
+
+// playedMove records a move played in the game for undoing and PGN export
+type playedMove struct {
+	move    boardMove
+	san     string
+	comment string
+}
+
+// SearchInfo holds statistics of an engine search
+type SearchInfo struct {
+	Depth    int           // Depth searched in plies
+	Nodes    int           // Board states evaluated by all threads
+	QNodes   int           // Part of Nodes evaluated in quiescence search
+	Score    float32       // Score of chosen move from engine's perspective
+	Mate     int           // Moves to mate, negative if engine gets mated
+	Duration time.Duration // Time spent in search
+	PV       []UserMove    // Principal variation starting with best move
 }
 
-var game GameState
+// NewGame sets up a new game from the initial position. colorChoice is the
+// color played by the user.
+func NewGame(colorChoice int) *Game {
+	g := newGame(newBoard(), colorChoice)
+	g.turn = white
+	g.castling = allCastling
+	g.board.key ^= g.stateKey()
+	return g
+}
 
-// InitGame sets up a new game.
-func InitGame(colorChoice int) {
+// newGame starts a game on given board. Piece lists and material balance
+// are derived from the pieces on the board.
+func newGame(board *boardConfig, colorChoice int) *Game {
 	whitePieces := make(map[int][]*piece, 16)
 	blackPieces := make(map[int][]*piece, 16)
-	board := newBoard()
-	game = GameState{}
-	game.board = board
-	game.moveCount = 0
-	for i := 0; i <= 15; i++ {
-		piece := board.pieces[i]
-		whitePieces[piece.id] = append(whitePieces[piece.id], piece)
-	}
-	for i := 48; i <= 63; i++ {
-		piece := board.pieces[i]
-		blackPieces[piece.id] = append(blackPieces[piece.id], piece)
+	g := &Game{}
+	g.board = board
+	g.moveCount = 0
+	g.started = time.Now()
+	g.maxDepth = defaultDepth
+	g.pruning = DefaultPruning
+	g.threads = 1
+	balance := 0
+	for _, piece := range board.pieces {
+		if piece == nil {
+			continue
+		}
+		if piece.color == white {
+			whitePieces[piece.id] = append(whitePieces[piece.id], piece)
+			balance += weights[piece.id]
+		} else {
+			blackPieces[piece.id] = append(blackPieces[piece.id], piece)
+			balance -= weights[piece.id]
+		}
 	}
 	if colorChoice == white {
-		game.myColor = black
-		game.otherPieces = whitePieces
-		game.myPieces = blackPieces
-		return
-	}
-	game.myColor = white
-	game.otherPieces = blackPieces
-	game.myPieces = whitePieces
+		g.myColor = black
+		g.otherPieces = whitePieces
+		g.myPieces = blackPieces
+		g.materialBalance = -balance
+		return g
+	}
+	g.myColor = white
+	g.otherPieces = blackPieces
+	g.myPieces = whitePieces
+	g.materialBalance = balance
+	return g
+}
+
+// Copy returns an independent game with the same moves played, which can be
+// altered or searched without affecting this one.
+func (g *Game) Copy() *Game {
+	fen := g.startFEN
+	if fen == "" {
+		fen = StartFEN
+	}
+	// Position was valid when the game was set up
+	c, _ := NewGameFromFEN(fen, otherColor(g.myColor))
+	c.started = g.started
+	c.maxDepth = g.maxDepth
+	for _, played := range g.history {
+		bm, _ := c.fromSAN(played.san)
+		c.PlayMove(bm)
+		c.history[len(c.history)-1].comment = played.comment
+	}
+	return c
 }
 
 // MakeMove verifies if the user move if valid
-func MakeMove(uMove boardMove) error {
-	board := game.board
-	piece := board.pieces[uMove.From]
-	if piece.color == game.myColor {
+func (g *Game) MakeMove(uMove boardMove) error {
+	piece := g.board.pieces[uMove.From]
+	if piece != nil && piece.color == g.myColor {
 		return errors.New("Cannot move opponent piece")
 	}
+	return g.PlayMove(uMove)
+}
+
+// PlayMove verifies and applies a move for the side to move, irrespective
+// of whether it is the engine or the user. Protocol front-ends use this to
+// replay move lists sent by a GUI.
+func (g *Game) PlayMove(uMove boardMove) error {
+	board := g.board
+	piece := board.pieces[uMove.From]
+	if piece == nil {
+		return errors.New("Invalid move")
+	}
+	if piece.color != g.sideToMove() {
+		return errors.New("Not this side's turn to move")
+	}
 	valid := false
-	moves, _ := piece.moveGenerator(piece)
+	moves, _ := piece.moveGenerator(g, piece)
 	for _, move := range moves {
 		if uMove == move {
 			valid = true
@@ -78,30 +215,124 @@ func MakeMove(uMove boardMove) error {
 	if !valid {
 		return errors.New("Invalid move")
 	}
-	if !isMoveLegal(uMove) {
+	if !g.isMoveLegal(uMove) {
 		return errors.New("Illegal move")
 	}
-	return board.alterPosition(uMove)
+	san := g.toSAN(uMove)
+	err := g.alterPosition(uMove)
+	if err != nil {
+		return err
+	}
+	g.history = append(g.history, playedMove{uMove, san, ""})
+	return nil
 }
 
-// MyMove computes a move for the engine
-func MyMove() (UserMove, error) {
-	evaluationsPerSearch = 0
-	myMov, _ := search(true, float32(math.MaxInt32), 1, []boardMove{})
-	fmt.Printf("Evaluated %d board states\n", evaluationsPerSearch)
-	err := game.board.alterPosition(myMov)
+// UndoMove takes back the last move played, by either side
+func (g *Game) UndoMove() error {
+	if len(g.history) == 0 {
+		return errors.New("No moves to undo")
+	}
+	last := len(g.history) - 1
+	g.undoMove(g.history[last].move)
+	g.history = g.history[:last]
+	return nil
+}
+
+// SwitchSides hands over the engine's pieces to the user and vice versa
+func (g *Game) SwitchSides() {
+	if g.myColor == white {
+		g.myColor = black
+	} else {
+		g.myColor = white
+	}
+	g.myPieces, g.otherPieces = g.otherPieces, g.myPieces
+	g.materialBalance = -g.materialBalance
+}
+
+// WhiteToMove tells whether it is white's turn to move
+func (g *Game) WhiteToMove() bool {
+	return g.sideToMove() == white
+}
+
+// MoveNumber returns the number of the current full move, starting at one
+// and incremented after black's move
+func (g *Game) MoveNumber() int {
+	return (g.startPly+g.moveCount)/2 + 1
+}
+
+// MyTurn tells whether it is the engine's turn to move
+func (g *Game) MyTurn() bool {
+	return g.sideToMove() == g.myColor
+}
+
+// LegalMoves lists the legal moves of the side to move along with their
+// Standard Algebraic Notation
+func (g *Game) LegalMoves() []UserMove {
+	moves := make([]UserMove, 0)
+	for pieceType := king; pieceType <= pawn; pieceType++ {
+		for _, move := range g.legalMovesOf(g.turn, pieceType) {
+			uMove, err := move.toUserMove()
+			if err != nil {
+				continue
+			}
+			uMove.SAN = g.toSAN(move)
+			moves = append(moves, uMove)
+		}
+	}
+	return moves
+}
+
+// OnIteration sets a function to be called with the outcome of each
+// completed iteration of the engine's searches
+func (g *Game) OnIteration(report func(SearchInfo)) {
+	g.onIteration = report
+}
+
+// MyMove computes a move for the engine and plays it on the board
+func (g *Game) MyMove() (UserMove, SearchInfo, error) {
+	return g.MyMoveWithin(context.Background(), SearchLimits{})
+}
+
+// MyMoveWithin computes a move for the engine within given search limits
+// and plays it on the board. The search may be stopped from another
+// goroutine by cancelling the context. If the search is stopped or runs out
+// of time or nodes, the best move found so far is played.
+func (g *Game) MyMoveWithin(
+	ctx context.Context, limits SearchLimits) (UserMove, SearchInfo, error) {
+	myTurn := g.MyTurn()
+	if !myTurn {
+		return UserMove{}, SearchInfo{}, errors.New("Not the engine's turn")
+	}
+	if g.legalMoves(myTurn) == 0 {
+		return UserMove{}, SearchInfo{}, errors.New("No legal moves")
+	}
+	if g.tt == nil {
+		g.tt = NewTranspositionTable(DefaultHashSize)
+	}
+	g.tt.newSearch()
+	myMov, info := g.think(ctx, limits)
+	san := g.toSAN(myMov)
+	err := g.alterPosition(myMov)
 	if err != nil {
-		return UserMove{}, err
+		return UserMove{}, info, err
 	}
+	g.history = append(
+		g.history, playedMove{myMov, san, g.searchComment(info)})
 	myMoveCoord, err := myMov.toUserMove()
 	if err != nil {
-		return UserMove{}, err
+		return UserMove{}, info, err
 	}
-	return myMoveCoord, nil
+	myMoveCoord.SAN = san
+	return myMoveCoord, info, nil
+}
+
+// sideToMove returns color of the side whose turn it is
+func (g *Game) sideToMove() int {
+	return g.turn
 }
 
-func isMoveLegal(mv boardMove) bool {
-	board := game.board
+func (g *Game) isMoveLegal(mv boardMove) bool {
+	board := g.board
 	pc := board.pieces[mv.From]
 	if pc.captured {
 		// Cannot move dead piece
@@ -118,28 +349,19 @@ func isMoveLegal(mv boardMove) bool {
 			return false
 		}
 	}
-	var kingPc *piece
-	var otherPieces map[int][]*piece
-	if pc.color == game.myColor {
-		kingPc = game.myPieces[king][0]
-		otherPieces = game.otherPieces
-	} else {
-		kingPc = game.otherPieces[king][0]
-		otherPieces = game.myPieces
-	}
-	if mv.castlingFrom > 0 {
+	if mv.castlingFrom != -1 {
 		// Check if castling is valid. It inherently checks if move
 		// results in check.
-		return isCastlingValid(mv, otherPieces)
+		return g.isCastlingValid(mv)
 	}
-	defer board.undoMove(mv)
-	board.alterPosition(mv)
+	defer g.undoMove(mv)
+	g.alterPosition(mv)
 	// Check if move results in king in check
-	return !inCheck(kingPc, otherPieces)
+	return !g.inCheck(pc.color)
 }
 
-func isMoveValid(mv boardMove) bool {
-	board := game.board
+func (g *Game) isMoveValid(mv boardMove) bool {
+	board := g.board
 	pc := board.pieces[mv.From]
 	if pc.captured {
 		// Cannot move dead piece
@@ -156,87 +378,63 @@ func isMoveValid(mv boardMove) bool {
 			return false
 		}
 	}
-	var otherPieces map[int][]*piece
-	if pc.color == game.myColor {
-		otherPieces = game.otherPieces
-	} else {
-		otherPieces = game.myPieces
-	}
-	if mv.castlingFrom > 0 {
+	if mv.castlingFrom != -1 {
 		// Check if castling is valid. It inherently checks if move
 		// results in check.
-		return isCastlingValid(mv, otherPieces)
+		return g.isCastlingValid(mv)
 	}
 	return true
 }
 
-func isCastlingValid(bm boardMove, otherPieces map[int][]*piece) bool {
-	for _, pieces := range otherPieces {
-		for _, piece := range pieces {
-			if piece.captured {
-				continue
-			}
-			moves, _ := piece.moveGenerator(piece)
-			for _, move := range moves {
-				if move.To == bm.From ||
-					move.To == bm.To ||
-					move.To == bm.castlingTo {
-					return false
-				}
-			}
+// isCastlingValid tells whether the king is out of check and neither
+// passes through nor lands on a square attacked by the opponent
+func (g *Game) isCastlingValid(bm boardMove) bool {
+	color := g.board.pieces[bm.From].color
+	for _, square := range []int{bm.From, bm.castlingTo, bm.To} {
+		if g.isAttacked(square, otherColor(color)) {
+			return false
 		}
 	}
 	return true
 }
 
-func inCheckSimple(myTurn bool, attacks uint) bool {
+func (g *Game) inCheckSimple(myTurn bool, attacks uint64) bool {
 	var kingPc *piece
 	if myTurn {
-		kingPc = game.myPieces[king][0]
+		kingPc = g.myPieces[king][0]
 	} else {
-		kingPc = game.otherPieces[king][0]
+		kingPc = g.otherPieces[king][0]
 	}
-	return (kingPc.position & attacks) != 0
+	return (1<<uint(kingPc.square))&attacks != 0
 }
 
-func inCheck(kingPc *piece, otherPieces map[int][]*piece) bool {
-	brdIndex := int(math.Log2(float64(kingPc.position)))
-	for _, pieces := range otherPieces {
-		for _, piece := range pieces {
-			if piece.captured {
-				continue
-			}
-			moves, _ := piece.moveGenerator(piece)
-			for _, move := range moves {
-				if move.To == brdIndex {
-					return true
-				}
-			}
-		}
-	}
-	return false
+// inCheck tells whether the king of given color is attacked
+func (g *Game) inCheck(color int) bool {
+	own, _ := g.sides(color)
+	return g.isAttacked(own[king][0].square, otherColor(color))
 }
 
-// GameStatus returns status of the current game
-func GameStatus(myTurn bool) int {
-	if legalMoves(myTurn) > 0 {
-		return InProgress
+// Status returns status of the game for the side to move
+func (g *Game) Status() int {
+	myTurn := g.MyTurn()
+	if g.legalMoves(myTurn) > 0 {
+		return g.drawStatus()
 	}
 	if myTurn {
-		if inCheck(game.myPieces[king][0], game.otherPieces) {
+		if g.inCheck(g.myColor) {
 			return Win
 		}
 		return Stalemate
 	}
-	if inCheck(game.otherPieces[king][0], game.myPieces) {
+	if g.inCheck(otherColor(g.myColor)) {
 		return Lost
 	}
 	return Stalemate
 }
 
 // IsPromotion tells whether given move corresponds to pawn promotion
-func IsPromotion(mv boardMove) bool {
-	piece := game.board.pieces[mv.From]
+func (g *Game) IsPromotion(mv boardMove) bool {
+	piece := g.board.pieces[mv.From]
 	if piece == nil || piece.id != pawn {
 		return false
 	}
@@ -251,27 +449,14 @@ func IsPromotion(mv boardMove) bool {
 }
 
 // PrintBoard prints entire board state. Useful for testing.
-func PrintBoard() {
+func (g *Game) PrintBoard() {
 	names := make([]string, 0)
 	lines := make([]string, 0)
-	for i, pc := range game.board.pieces {
+	for i, pc := range g.board.pieces {
 		if pc == nil {
 			names = append(names, "-")
 		} else {
-			switch pc.id {
-			case king:
-				names = append(names, "K")
-			case queen:
-				names = append(names, "Q")
-			case rook:
-				names = append(names, "R")
-			case bishop:
-				names = append(names, "B")
-			case knight:
-				names = append(names, "N")
-			case pawn:
-				names = append(names, "P")
-			}
+			names = append(names, pieceLetters[pc.id])
 		}
 		if (i+1)%8 == 0 {
 			nameStr := strings.Join(names, " ")
@@ -0,0 +1,24 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestMyMoveTurn(t *testing.T) {
+	// User plays white, who is to move
+	g := NewGame(white)
+	if _, _, err := g.MyMove(); err == nil {
+		t.Error("MyMove() played on the user's turn")
+	}
+	if fen := g.FEN(); fen != StartFEN {
+		t.Errorf("position changed to %s", fen)
+	}
+	// Stalemated side to move
+	g, err := NewGameFromFEN("7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", white)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := g.MyMove(); err == nil {
+		t.Error("MyMove() played without legal moves")
+	}
+}
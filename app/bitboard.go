@@ -0,0 +1,111 @@
+package app
+
+import (
+	"math/bits"
+)
+
+// Bitboards are sets of squares with bit i set for board index i
+
+// Ray directions. Positive directions increase the board index.
+const (
+	north = iota
+	south
+	east
+	west
+	northEast
+	northWest
+	southEast
+	southWest
+)
+
+// Rank and file steps of each ray direction
+var directionSteps = [8][2]int{
+	north:     {1, 0},
+	south:     {-1, 0},
+	east:      {0, 1},
+	west:      {0, -1},
+	northEast: {1, 1},
+	northWest: {1, -1},
+	southEast: {-1, 1},
+	southWest: {-1, -1},
+}
+
+var rookDirections = []int{north, south, east, west}
+
+var bishopDirections = []int{northEast, northWest, southEast, southWest}
+
+// Squares attacked from each square on an empty board
+var (
+	knightAttacks [64]uint64
+	kingAttacks   [64]uint64
+	pawnAttacks   [3][64]uint64 // Indexed by color of the pawn
+	rays          [8][64]uint64 // Indexed by direction
+)
+
+func init() {
+	knightJumps := [][2]int{
+		{2, 1}, {1, 2}, {-1, 2}, {-2, 1}, {-2, -1}, {-1, -2}, {1, -2}, {2, -1},
+	}
+	for square := 0; square < 64; square++ {
+		rank, file := getRankFile(square)
+		for _, jump := range knightJumps {
+			knightAttacks[square] |= squareBit(rank+jump[0], file+jump[1])
+		}
+		for dir, step := range directionSteps {
+			kingAttacks[square] |= squareBit(rank+step[0], file+step[1])
+			r, f := rank+step[0], file+step[1]
+			for ; squareBit(r, f) != 0; r, f = r+step[0], f+step[1] {
+				rays[dir][square] |= squareBit(r, f)
+			}
+		}
+		pawnAttacks[white][square] = squareBit(rank+1, file-1) |
+			squareBit(rank+1, file+1)
+		pawnAttacks[black][square] = squareBit(rank-1, file-1) |
+			squareBit(rank-1, file+1)
+	}
+	initMagics()
+}
+
+// squareBit returns the bitboard of the square at given rank and file, or
+// an empty bitboard if the square is off the board
+func squareBit(rank, file int) uint64 {
+	if rank < 1 || rank > 8 || file < 1 || file > 8 {
+		return 0
+	}
+	return 1 << uint(8*(rank-1)+file-1)
+}
+
+// rayAttacks returns the squares attacked along a ray up to and including
+// the first occupied square
+func rayAttacks(dir, square int, occupied uint64) uint64 {
+	attacks := rays[dir][square]
+	blockers := attacks & occupied
+	if blockers == 0 {
+		return attacks
+	}
+	blocker := 63 - bits.LeadingZeros64(blockers)
+	if dir == north || dir == east || dir == northEast || dir == northWest {
+		blocker = bits.TrailingZeros64(blockers)
+	}
+	return attacks &^ rays[dir][blocker]
+}
+
+// rookRayAttacks walks the rays of a rook. Move generation uses the faster
+// rookAttacks.
+func rookRayAttacks(square int, occupied uint64) uint64 {
+	var attacks uint64
+	for _, dir := range rookDirections {
+		attacks |= rayAttacks(dir, square, occupied)
+	}
+	return attacks
+}
+
+// bishopRayAttacks walks the rays of a bishop. Move generation uses the
+// faster bishopAttacks.
+func bishopRayAttacks(square int, occupied uint64) uint64 {
+	var attacks uint64
+	for _, dir := range bishopDirections {
+		attacks |= rayAttacks(dir, square, occupied)
+	}
+	return attacks
+}
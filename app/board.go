@@ -5,141 +5,235 @@ import (
 	"math"
 )
 
+// boardConfig holds the pieces on each square along with bitboards of the
+// squares occupied by each color and piece type
 type boardConfig struct {
 	pieces []*piece
+	colors [3]uint64 // Indexed by color
+	types  [7]uint64 // Indexed by piece type
+	key    uint64    // Zobrist key of the position
 }
 
 func newBoard() *boardConfig {
 	pieces := make([]*piece, 64)
-	pieces[0] = newWhitePiece(rook, 1<<0)
-	pieces[1] = newWhitePiece(knight, 1<<1)
-	pieces[2] = newWhitePiece(bishop, 1<<2)
-	pieces[3] = newWhitePiece(queen, 1<<3)
-	pieces[4] = newWhitePiece(king, 1<<4)
-	pieces[5] = newWhitePiece(bishop, 1<<5)
-	pieces[6] = newWhitePiece(knight, 1<<6)
-	pieces[7] = newWhitePiece(rook, 1<<7)
+	backRank := []int{rook, knight, bishop, queen, king, bishop, knight, rook}
+	for file, pieceType := range backRank {
+		pieces[file] = newWhitePiece(pieceType, file)
+		pieces[56+file] = newBlackPiece(pieceType, 56+file)
+	}
 	for i := 8; i <= 15; i++ {
-		pieces[i] = newWhitePiece(pawn, 1<<i)
+		pieces[i] = newWhitePiece(pawn, i)
 	}
 	for i := 48; i <= 55; i++ {
-		pieces[i] = newBlackPiece(pawn, 1<<i)
+		pieces[i] = newBlackPiece(pawn, i)
+	}
+	return boardFromPieces(pieces)
+}
+
+// boardFromPieces builds a board with pieces placed on given squares
+func boardFromPieces(pieces []*piece) *boardConfig {
+	brd := &boardConfig{pieces: make([]*piece, 64)}
+	for _, pc := range pieces {
+		if pc != nil {
+			brd.put(pc)
+		}
 	}
-	pieces[56] = newBlackPiece(rook, 1<<56)
-	pieces[57] = newBlackPiece(knight, 1<<57)
-	pieces[58] = newBlackPiece(bishop, 1<<58)
-	pieces[59] = newBlackPiece(queen, 1<<59)
-	pieces[60] = newBlackPiece(king, 1<<60)
-	pieces[61] = newBlackPiece(bishop, 1<<61)
-	pieces[62] = newBlackPiece(knight, 1<<62)
-	pieces[63] = newBlackPiece(rook, 1<<63)
-	return &boardConfig{pieces}
+	return brd
+}
+
+// occupied returns the squares occupied by pieces of either color
+func (brd *boardConfig) occupied() uint64 {
+	return brd.colors[white] | brd.colors[black]
+}
+
+// put places a piece on its square
+func (brd *boardConfig) put(pc *piece) {
+	brd.pieces[pc.square] = pc
+	brd.colors[pc.color] |= 1 << uint(pc.square)
+	brd.types[pc.id] |= 1 << uint(pc.square)
+	brd.key ^= zobristPieces[pc.color][pc.id][pc.square]
 }
 
-func (brd *boardConfig) alterPosition(bm boardMove) error {
+// remove lifts a piece off its square
+func (brd *boardConfig) remove(pc *piece) {
+	brd.pieces[pc.square] = nil
+	brd.colors[pc.color] &^= 1 << uint(pc.square)
+	brd.types[pc.id] &^= 1 << uint(pc.square)
+	brd.key ^= zobristPieces[pc.color][pc.id][pc.square]
+}
+
+// move moves a piece to given square, which must be empty
+func (brd *boardConfig) move(pc *piece, square int) {
+	brd.remove(pc)
+	pc.square = square
+	brd.put(pc)
+}
+
+func (g *Game) alterPosition(bm boardMove) error {
+	brd := g.board
 	pc := brd.pieces[bm.From]
 	if pc == nil {
 		return errors.New("Invalid move")
 	}
 	capturedPc := bm.captured
+	g.states = append(g.states, moveState{
+		turn:          g.turn,
+		halfmoveClock: g.halfmoveClock,
+		castling:      g.castling,
+		key:           brd.key,
+	})
+	// Side to move, castling and en passant keys are replaced once the
+	// move is made. Keys of pieces are updated as they move.
+	brd.key ^= g.stateKey()
+	if pc.id == king {
+		g.castling &^= colorCastling[pc.color]
+	}
+	// Moving a rook from or capturing a rook on its home square loses the
+	// right to castle with it
+	g.castling &^= rookCastling[bm.From] | rookCastling[bm.To]
+	if pc.id == pawn || capturedPc != nil {
+		g.halfmoveClock = 0
+	} else {
+		g.halfmoveClock += 1
+	}
+	g.turn = otherColor(pc.color)
 	if capturedPc != nil {
 		capturedPc.captured = true
-		if capturedPc.color != game.myColor {
-			game.materialBalance += weights[capturedPc.id]
+		if capturedPc.color != g.myColor {
+			g.materialBalance += weights[capturedPc.id]
 		} else {
-			game.materialBalance -= weights[capturedPc.id]
+			g.materialBalance -= weights[capturedPc.id]
 		}
+		// Captured piece is on the target square unless taken en passant
+		brd.remove(capturedPc)
 	}
-	brd.pieces[bm.From] = nil
-	brd.pieces[bm.To] = pc
-	pc.position = 1 << bm.To
-	game.moveCount += 1
+	brd.move(pc, bm.To)
+	g.moveCount += 1
 	pc.moveCount += 1
-	if bm.castlingFrom != -1 {
+	switch {
+	case bm.castlingFrom != -1:
 		rookPc := brd.pieces[bm.castlingFrom]
-		brd.pieces[bm.castlingFrom] = nil
-		brd.pieces[bm.castlingTo] = rookPc
-		rookPc.position = 1 << bm.castlingTo
+		brd.move(rookPc, bm.castlingTo)
 		rookPc.moveCount += 1
-		return nil
-	}
-	if pc.id == pawn && int(math.Abs(float64(bm.To-bm.From))) == 16 {
-		pc.enpassantMove = game.moveCount
-		return nil
-	}
-	if bm.PromotedPc <= 0 {
-		return nil
+	case pc.id == pawn && int(math.Abs(float64(bm.To-bm.From))) == 16:
+		pc.enpassantMove = g.moveCount
+	case bm.PromotedPc > 0:
+		g.promote(pc, bm.PromotedPc)
 	}
+	brd.key ^= g.stateKey()
+	return nil
+}
+
+// promote replaces a pawn which reached the last rank by given piece
+func (g *Game) promote(pc *piece, pieceType int) {
 	var newPc *piece
 	if pc.color == black {
-		newPc = newBlackPiece(bm.PromotedPc, pc.position)
+		newPc = newBlackPiece(pieceType, pc.square)
 	} else {
-		newPc = newWhitePiece(bm.PromotedPc, pc.position)
+		newPc = newWhitePiece(pieceType, pc.square)
 	}
-	brd.pieces[bm.To] = newPc
+	g.board.remove(pc)
+	g.board.put(newPc)
 	newPc.promotedBy = pc
 	pc.captured = true
-	if pc.color == game.myColor {
-		game.materialBalance += weights[bm.PromotedPc]
-		game.materialBalance -= weights[pc.id]
-		game.myPieces[newPc.id] = append(game.myPieces[newPc.id], newPc)
-		return nil
+	if pc.color == g.myColor {
+		g.materialBalance += weights[pieceType]
+		g.materialBalance -= weights[pc.id]
+		g.myPieces[newPc.id] = append(g.myPieces[newPc.id], newPc)
+		return
 	}
-	game.materialBalance -= weights[bm.PromotedPc]
-	game.materialBalance += weights[pc.id]
-	game.otherPieces[newPc.id] = append(game.otherPieces[newPc.id], newPc)
-	return nil
+	g.materialBalance -= weights[pieceType]
+	g.materialBalance += weights[pc.id]
+	g.otherPieces[newPc.id] = append(g.otherPieces[newPc.id], newPc)
 }
 
-func (brd *boardConfig) undoMove(bm boardMove) {
-	game.moveCount -= 1
+func (g *Game) undoMove(bm boardMove) {
+	brd := g.board
+	brd.key ^= g.stateKey()
+	g.moveCount -= 1
+	last := len(g.states) - 1
+	g.turn = g.states[last].turn
+	g.halfmoveClock = g.states[last].halfmoveClock
+	g.castling = g.states[last].castling
+	g.states = g.states[:last]
 	pc := brd.pieces[bm.To]
-	brd.pieces[bm.From] = pc
+	brd.move(pc, bm.From)
 	capturedPc := bm.captured
 	if capturedPc != nil {
 		capturedPc.captured = false
-		if capturedPc.color != game.myColor {
-			game.materialBalance -= weights[capturedPc.id]
+		if capturedPc.color != g.myColor {
+			g.materialBalance -= weights[capturedPc.id]
 		} else {
-			game.materialBalance += weights[capturedPc.id]
+			g.materialBalance += weights[capturedPc.id]
 		}
+		brd.put(capturedPc)
 	}
-	brd.pieces[bm.To] = capturedPc
-	pc.position = 1 << bm.From
 	pc.moveCount -= 1
-	if bm.castlingFrom != -1 {
+	switch {
+	case bm.castlingFrom != -1:
 		rookPc := brd.pieces[bm.castlingTo]
-		brd.pieces[bm.castlingFrom] = rookPc
-		brd.pieces[bm.castlingTo] = nil
-		rookPc.position = 1 << bm.castlingFrom
+		brd.move(rookPc, bm.castlingFrom)
 		rookPc.moveCount -= 1
-		return
-	}
-	if pc.id == pawn && int(math.Abs(float64(bm.To-bm.From))) == 16 {
+	case pc.id == pawn && int(math.Abs(float64(bm.To-bm.From))) == 16:
 		pc.enpassantMove = -1
-		return
+	case pc.promotedBy != nil && pc.moveCount < 0:
+		// Move to be undone is a promotion move
+		g.unpromote(pc)
 	}
+	brd.key ^= g.stateKey()
+}
+
+// makeNullMove passes the turn to the other side without moving a piece,
+// as done by null-move pruning
+func (g *Game) makeNullMove() {
+	brd := g.board
+	g.states = append(g.states, moveState{
+		turn:          g.turn,
+		halfmoveClock: g.halfmoveClock,
+		castling:      g.castling,
+		key:           brd.key,
+	})
+	brd.key ^= g.stateKey()
+	g.turn = otherColor(g.turn)
+	// Positions before the null move must not count as repeated after it
+	g.halfmoveClock = 0
+	// En passant captures are only possible right after the pawn moved
+	g.moveCount += 1
+	brd.key ^= g.stateKey()
+}
+
+// undoNullMove takes back a null move
+func (g *Game) undoNullMove() {
+	brd := g.board
+	brd.key ^= g.stateKey()
+	g.moveCount -= 1
+	last := len(g.states) - 1
+	g.turn = g.states[last].turn
+	g.halfmoveClock = g.states[last].halfmoveClock
+	g.castling = g.states[last].castling
+	g.states = g.states[:last]
+	brd.key ^= g.stateKey()
+}
+
+// unpromote puts back the pawn which promoted to given piece
+func (g *Game) unpromote(pc *piece) {
 	pwn := pc.promotedBy
-	// Check if move to be undone is a promotion move
-	if pwn == nil || pc.moveCount >= 0 {
-		return
-	}
-	brd.pieces[bm.From] = pwn
-	pwn.position = pc.position
+	g.board.remove(pc)
+	pwn.square = pc.square
+	g.board.put(pwn)
 	pwn.moveCount -= 1
 	pwn.captured = false
-	if pwn.color == game.myColor {
-		game.materialBalance += weights[pwn.id]
-		game.materialBalance -= weights[pc.id]
+	if pwn.color == g.myColor {
+		g.materialBalance += weights[pwn.id]
+		g.materialBalance -= weights[pc.id]
 		// Remove promoted piece from list
-		pieces := game.myPieces[pc.id]
-		game.myPieces[pc.id] = pieces[:len(pieces)-1]
+		pieces := g.myPieces[pc.id]
+		g.myPieces[pc.id] = pieces[:len(pieces)-1]
 		return
 	}
-	game.materialBalance -= weights[pwn.id]
-	game.materialBalance += weights[pc.id]
+	g.materialBalance -= weights[pwn.id]
+	g.materialBalance += weights[pc.id]
 	// Remove promoted piece from list
-	pieces := game.otherPieces[pc.id]
-	game.otherPieces[pc.id] = pieces[:len(pieces)-1]
-
+	pieces := g.otherPieces[pc.id]
+	g.otherPieces[pc.id] = pieces[:len(pieces)-1]
 }
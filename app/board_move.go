@@ -14,6 +14,7 @@ type boardMove struct {
 	castlingTo   int
 	captured     *piece
 	PromotedPc   int
+	enpassant    bool // Captured pawn is beside the square moved to
 }
 
 func (m boardMove) String() string {
@@ -29,7 +30,11 @@ func (m boardMove) toUserMove() (UserMove, error) {
 	if err != nil {
 		return UserMove{}, err
 	}
-	return UserMove{fromCoord, toCoord}, nil
+	return UserMove{
+		From:      fromCoord,
+		To:        toCoord,
+		Promotion: promotionLetters[m.PromotedPc],
+	}, nil
 }
 
 func toCoordinates(index int) (string, error) {
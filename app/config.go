@@ -1,3 +1,38 @@
 package app
 
-const maxDepth = 4
+const defaultDepth = 4
+
+// SetMaxDepth limits the engine search to given number of plies. Values
+// less than 1 restore the default depth.
+func (g *Game) SetMaxDepth(depth int) {
+	if depth < 1 {
+		depth = defaultDepth
+	}
+	g.maxDepth = depth
+}
+
+// Pruning switches the selective search techniques on or off, so that their
+// effect can be measured
+type Pruning struct {
+	NullMove   bool // Null-move pruning
+	Reductions bool // Late move reductions
+	Futility   bool // Futility and reverse futility pruning
+}
+
+// DefaultPruning has all selective search techniques on
+var DefaultPruning = Pruning{NullMove: true, Reductions: true, Futility: true}
+
+// SetPruning chooses the selective search techniques used by the engine
+func (g *Game) SetPruning(pruning Pruning) {
+	g.pruning = pruning
+}
+
+// SetThreads chooses the number of threads searching for the engine's
+// moves. A single thread, as with values less than 2, searches the same
+// way every time, while more threads find better moves in the same time.
+func (g *Game) SetThreads(threads int) {
+	if threads < 1 {
+		threads = 1
+	}
+	g.threads = threads
+}
@@ -15,3 +15,26 @@ const (
 )
 
 var promotablePieces = []int{queen, rook, bishop, knight}
+
+// Castling rights
+const (
+	whiteKingSide  = 1
+	whiteQueenSide = 2
+	blackKingSide  = 4
+	blackQueenSide = 8
+	allCastling    = 15
+)
+
+// Castling rights lost when king of given color moves
+var colorCastling = map[int]int{
+	white: whiteKingSide | whiteQueenSide,
+	black: blackKingSide | blackQueenSide,
+}
+
+// Castling rights lost when a rook leaves or is captured on given square
+var rookCastling = map[int]int{
+	0:  whiteQueenSide,
+	7:  whiteKingSide,
+	56: blackQueenSide,
+	63: blackKingSide,
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"math/bits"
+)
+
+// repetitions counts earlier occurrences of the current position. Only
+// positions since the last capture or pawn move can repeat.
+func (g *Game) repetitions() int {
+	key := g.board.key
+	count := 0
+	last := len(g.states)
+	for i := last - 2; i >= 0 && i >= last-g.halfmoveClock; i -= 2 {
+		if g.states[i].key == key {
+			count += 1
+		}
+	}
+	return count
+}
+
+// lightSquares are the squares of the same color as h1
+const lightSquares uint64 = 0x55aa55aa55aa55aa
+
+// insufficientMaterial tells whether neither side has enough pieces left to
+// checkmate: bare kings, a single minor piece or only bishops on squares of
+// the same color.
+func (g *Game) insufficientMaterial() bool {
+	brd := g.board
+	minors := brd.types[bishop] | brd.types[knight]
+	if brd.occupied() != brd.types[king]|minors {
+		return false
+	}
+	if bits.OnesCount64(minors) <= 1 {
+		return true
+	}
+	bishops := brd.types[bishop]
+	return bishops == minors &&
+		(bishops&lightSquares == 0 || bishops&^lightSquares == 0)
+}
+
+// drawStatus tells whether the game is drawn, given that the side to move
+// has legal moves. Threefold repetition and the fifty move rule are claimed
+// on behalf of the players.
+func (g *Game) drawStatus() int {
+	reps := g.repetitions()
+	switch {
+	case reps >= 4:
+		return FivefoldRepetition
+	case g.halfmoveClock >= 150:
+		return SeventyFiveMoves
+	case g.insufficientMaterial():
+		return InsufficientMaterial
+	case reps >= 2:
+		return Repetition
+	case g.halfmoveClock >= 100:
+		return FiftyMoves
+	}
+	return InProgress
+}
+
+// isDraw tells search whether to score the current position as a draw.
+// Unlike drawStatus a single repetition is enough, since nothing can be
+// gained by repeating the position that could not be gained the first time.
+func (g *Game) isDraw() bool {
+	return g.halfmoveClock >= 100 || g.insufficientMaterial() ||
+		g.repetitions() >= 1
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"testing"
+)
+
+// playSAN plays moves in Standard Algebraic Notation on given game
+func playSAN(t *testing.T, g *Game, moves ...string) {
+	t.Helper()
+	for _, san := range moves {
+		bm, err := g.ParseSAN(san)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.PlayMove(bm); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRepetition(t *testing.T) {
+	shuffle := []string{"Nf3", "Nf6", "Ng1", "Ng8"}
+	g := NewGame(black)
+	playSAN(t, g, shuffle...)
+	if status := g.Status(); status != InProgress {
+		t.Errorf("Status() = %d after one repetition, want %d", status,
+			InProgress)
+	}
+	if !g.isDraw() {
+		t.Error("isDraw() = false after one repetition")
+	}
+	playSAN(t, g, shuffle...)
+	if status := g.Status(); status != Repetition {
+		t.Errorf("Status() = %d after two repetitions, want %d", status,
+			Repetition)
+	}
+	playSAN(t, g, shuffle...)
+	playSAN(t, g, shuffle...)
+	if status := g.Status(); status != FivefoldRepetition {
+		t.Errorf("Status() = %d after four repetitions, want %d", status,
+			FivefoldRepetition)
+	}
+}
+
+func TestMoveRules(t *testing.T) {
+	tests := []struct {
+		clock  int
+		status int
+	}{
+		{97, InProgress},
+		{98, FiftyMoves},
+		{147, FiftyMoves},
+		{148, SeventyFiveMoves},
+	}
+	for _, test := range tests {
+		fen := "4k3/8/8/8/8/8/8/R3K3 w - - 0 80"
+		g, err := NewGameFromFEN(fen, black)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.halfmoveClock = test.clock
+		playSAN(t, g, "Ra2", "Kd8")
+		if status := g.Status(); status != test.status {
+			t.Errorf("Status() = %d with halfmove clock %d, want %d",
+				status, g.halfmoveClock, test.status)
+		}
+	}
+	// A pawn move resets the clock
+	g, err := NewGameFromFEN("4k3/8/8/8/8/8/4P3/4K3 w - - 99 80", black)
+	if err != nil {
+		t.Fatal(err)
+	}
+	playSAN(t, g, "e4")
+	if status := g.Status(); status != InProgress {
+		t.Errorf("Status() = %d after a pawn move, want %d", status,
+			InProgress)
+	}
+}
+
+func TestInsufficientMaterial(t *testing.T) {
+	tests := []struct {
+		name         string
+		fen          string
+		insufficient bool
+	}{
+		{"K v K", "4k3/8/8/8/8/8/8/4K3 w - - 0 1", true},
+		{"K+B v K", "4k3/8/8/8/8/8/8/2B1K3 w - - 0 1", true},
+		{"K v K+B", "2b1k3/8/8/8/8/8/8/4K3 w - - 0 1", true},
+		{"K+N v K", "4k3/8/8/8/8/8/8/1N2K3 w - - 0 1", true},
+		{"light bishops", "2b1k3/8/8/8/8/8/8/4KB2 w - - 0 1", true},
+		{"dark bishops", "4kb2/8/8/8/8/8/8/2B1K3 w - - 0 1", true},
+		{"two light bishops", "4k3/8/8/8/8/8/8/3BKB2 w - - 0 1", true},
+		{"opposite bishops", "2b1k3/8/8/8/8/8/8/2B1K3 w - - 0 1", false},
+		{"bishop pair", "4k3/8/8/8/8/8/8/2B1KB2 w - - 0 1", false},
+		{"K+N+N v K", "4k3/8/8/8/8/8/8/1N2K1N1 w - - 0 1", false},
+		{"K+B v K+N", "1n2k3/8/8/8/8/8/8/2B1K3 w - - 0 1", false},
+		{"K+P v K", "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", false},
+		{"K+R v K", "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", false},
+	}
+	for _, test := range tests {
+		g, err := NewGameFromFEN(test.fen, black)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := InProgress
+		if test.insufficient {
+			want = InsufficientMaterial
+		}
+		if status := g.Status(); status != want {
+			t.Errorf("%s: Status() = %d, want %d", test.name, status, want)
+		}
+		if g.isDraw() != test.insufficient {
+			t.Errorf("%s: isDraw() = %v", test.name, !test.insufficient)
+		}
+	}
+	// Captured pieces no longer count
+	g, err := NewGameFromFEN("4k3/8/8/8/8/8/3r4/4K3 w - - 0 1", black)
+	if err != nil {
+		t.Fatal(err)
+	}
+	playSAN(t, g, "Kxd2")
+	if status := g.Status(); status != InsufficientMaterial {
+		t.Errorf("Status() = %d after capture, want %d", status,
+			InsufficientMaterial)
+	}
+}
@@ -1,23 +1,25 @@
 package app
 
-import (
-	"math"
-)
+// mateScore is the score of a side which checkmated its opponent
+const mateScore = 100000
 
-func pawnStructure(myTurn bool) (isolated, doubled, blocked int) {
-	pieces := game.board.pieces
+// maxPly is the greatest number of plies searched from the root
+const maxPly = 128
+
+func (g *Game) pawnStructure(myTurn bool) (isolated, doubled, blocked int) {
+	pieces := g.board.pieces
 	var pawns []*piece
 	if myTurn {
-		pawns = game.myPieces[pawn]
+		pawns = g.myPieces[pawn]
 	} else {
-		pawns = game.otherPieces[pawn]
+		pawns = g.otherPieces[pawn]
 	}
 	columns := make([]int, 8)
 	for _, piece := range pawns {
 		if piece.captured {
 			continue
 		}
-		brdIndex := int(math.Log2(float64(piece.position)))
+		brdIndex := piece.square
 		rank, file := getRankFile(brdIndex)
 		columns[file-1] += 1
 		var blockedPos int
@@ -49,12 +51,12 @@ func pawnStructure(myTurn bool) (isolated, doubled, blocked int) {
 	return
 }
 
-func legalMoves(myTurn bool) int {
+func (g *Game) legalMoves(myTurn bool) int {
 	var pieceMap map[int][]*piece
 	if myTurn {
-		pieceMap = game.myPieces
+		pieceMap = g.myPieces
 	} else {
-		pieceMap = game.otherPieces
+		pieceMap = g.otherPieces
 	}
 	moveCount := 0
 	for _, pieces := range pieceMap {
@@ -62,9 +64,9 @@ func legalMoves(myTurn bool) int {
 			if piece.captured {
 				continue
 			}
-			moves, _ := piece.moveGenerator(piece)
+			moves, _ := piece.moveGenerator(g, piece)
 			for _, move := range moves {
-				if isMoveLegal(move) {
+				if g.isMoveLegal(move) {
 					moveCount += 1
 				}
 			}
@@ -73,22 +75,48 @@ func legalMoves(myTurn bool) int {
 	return moveCount
 }
 
-func eval() float32 {
-	moveCount := legalMoves(true)
-	if moveCount == 0 {
-		// TODO: may also be a stalemate
-		return float32(math.MinInt32)
-	}
-	_moveCount := legalMoves(false)
-	if _moveCount == 0 {
-		// TODO: may also be a stalemate
-		return float32(math.MaxInt32)
+// eval returns the static evaluation of the position from the engine's
+// perspective. Positions in check are left to the search, so a side to
+// move without legal moves is stalemated.
+func (g *Game) eval() float32 {
+	moveCount := g.legalMoves(true)
+	_moveCount := g.legalMoves(false)
+	if g.MyTurn() && moveCount == 0 || !g.MyTurn() && _moveCount == 0 {
+		return 0
 	}
-	isolated, doubled, blocked := pawnStructure(true)
-	_isolated, _doubled, _blocked := pawnStructure(false)
-	score := float32(game.materialBalance)
+	isolated, doubled, blocked := g.pawnStructure(true)
+	_isolated, _doubled, _blocked := g.pawnStructure(false)
+	score := float32(g.materialBalance)
 	score -= 0.5 * float32(isolated-_isolated+doubled-_doubled+
 		blocked-_blocked)
 	score += 0.1 * float32(moveCount-_moveCount)
 	return score
 }
+
+// evaluate returns the static evaluation of the position from the
+// perspective of the side to move
+func (g *Game) evaluate() float32 {
+	if g.MyTurn() {
+		return g.eval()
+	}
+	return -g.eval()
+}
+
+// isMateScore tells whether a score found by the search is due to a
+// checkmate
+func isMateScore(score float32) bool {
+	return score >= mateScore-maxPly || score <= -mateScore+maxPly
+}
+
+// mateIn returns the number of moves to checkmate of a score found by the
+// search, negative if the side to move gets mated, or 0 if the score is not
+// a mate score
+func mateIn(score float32) int {
+	switch {
+	case score >= mateScore-maxPly:
+		return (int(mateScore-score) + 1) / 2
+	case score <= -mateScore+maxPly:
+		return -int(mateScore+score) / 2
+	}
+	return 0
+}
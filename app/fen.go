@@ -0,0 +1,270 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// StartFEN is the initial position in Forsyth-Edwards Notation
+const StartFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// fenPosition holds the fields of a FEN record
+type fenPosition struct {
+	board         *boardConfig
+	turn          int
+	castling      int
+	epSquare      int // Board index of en passant target square or -1
+	halfmoveClock int
+	fullmove      int
+}
+
+// Castling rights in FEN along with home squares of the king and rook
+// involved
+var castlingSquares = map[rune]struct{ right, king, rook int }{
+	'K': {whiteKingSide, 4, 7},
+	'Q': {whiteQueenSide, 4, 0},
+	'k': {blackKingSide, 60, 63},
+	'q': {blackQueenSide, 60, 56},
+}
+
+func fenError(fen, reason string) error {
+	errMsg := fmt.Sprintf("Invalid FEN '%s': %s", fen, reason)
+	return errors.New(errMsg)
+}
+
+// parseFEN parses a position like
+// rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1
+func parseFEN(fen string) (*fenPosition, error) {
+	fields := strings.Fields(fen)
+	if len(fields) != 6 {
+		return nil, fenError(fen, "expected 6 fields")
+	}
+	pos := &fenPosition{epSquare: -1}
+	board, err := parsePlacement(fields[0])
+	if err != nil {
+		return nil, fenError(fen, err.Error())
+	}
+	pos.board = board
+	switch fields[1] {
+	case "w":
+		pos.turn = white
+	case "b":
+		pos.turn = black
+	default:
+		return nil, fenError(fen, "side to move must be 'w' or 'b'")
+	}
+	if fields[2] != "-" {
+		for _, right := range fields[2] {
+			squares, ok := castlingSquares[right]
+			if !ok || pos.castling&squares.right != 0 {
+				return nil, fenError(fen, "invalid castling rights")
+			}
+			pos.castling |= squares.right
+			kingPc := board.pieces[squares.king]
+			rookPc := board.pieces[squares.rook]
+			color := white
+			if right == 'k' || right == 'q' {
+				color = black
+			}
+			if kingPc == nil || kingPc.id != king || kingPc.color != color ||
+				rookPc == nil || rookPc.id != rook || rookPc.color != color {
+				return nil, fenError(
+					fen, "castling rights without king and rook in place")
+			}
+		}
+	}
+	if fields[3] != "-" {
+		epSquare, err := toIndex(fields[3])
+		if err != nil {
+			return nil, fenError(fen, err.Error())
+		}
+		rank, _ := getRankFile(epSquare)
+		if (pos.turn == white && rank != 6) ||
+			(pos.turn == black && rank != 3) {
+			return nil, fenError(fen, "invalid en passant square")
+		}
+		pos.epSquare = epSquare
+	}
+	pos.halfmoveClock, err = strconv.Atoi(fields[4])
+	if err != nil || pos.halfmoveClock < 0 {
+		return nil, fenError(fen, "invalid halfmove clock")
+	}
+	pos.fullmove, err = strconv.Atoi(fields[5])
+	if err != nil || pos.fullmove < 1 {
+		return nil, fenError(fen, "invalid fullmove number")
+	}
+	return pos, nil
+}
+
+// parsePlacement builds a board from the piece placement field of a FEN
+func parsePlacement(placement string) (*boardConfig, error) {
+	ranks := strings.Split(placement, "/")
+	if len(ranks) != 8 {
+		return nil, errors.New("expected 8 ranks")
+	}
+	pieces := make([]*piece, 64)
+	kings := map[int]int{}
+	for i, rankStr := range ranks {
+		rank := 8 - i
+		file := 1
+		for _, ch := range rankStr {
+			if ch >= '1' && ch <= '8' {
+				file += int(ch - '0')
+				continue
+			}
+			if file > 8 {
+				errMsg := fmt.Sprintf("rank %d has too many squares", rank)
+				return nil, errors.New(errMsg)
+			}
+			pieceType := 0
+			for id, letter := range pieceLetters {
+				if strings.ToUpper(string(ch)) == letter {
+					pieceType = id
+				}
+			}
+			if pieceType == 0 {
+				errMsg := fmt.Sprintf("unknown piece '%c'", ch)
+				return nil, errors.New(errMsg)
+			}
+			if pieceType == pawn && (rank == 1 || rank == 8) {
+				return nil, errors.New("pawn on first or last rank")
+			}
+			index := 8*(rank-1) + file - 1
+			if ch >= 'a' && ch <= 'z' {
+				pieces[index] = newBlackPiece(pieceType, index)
+			} else {
+				pieces[index] = newWhitePiece(pieceType, index)
+			}
+			if pieceType == king {
+				kings[pieces[index].color] += 1
+			}
+			file += 1
+		}
+		if file != 9 {
+			errMsg := fmt.Sprintf("rank %d does not have 8 squares", rank)
+			return nil, errors.New(errMsg)
+		}
+	}
+	if kings[white] != 1 || kings[black] != 1 {
+		return nil, errors.New("each side must have exactly one king")
+	}
+	return boardFromPieces(pieces), nil
+}
+
+// NewGameFromFEN sets up a new game from a position in Forsyth-Edwards
+// Notation. As with NewGame, colorChoice is the color played by the user.
+func NewGameFromFEN(fen string, colorChoice int) (*Game, error) {
+	pos, err := parseFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	if pos.epSquare != -1 {
+		// Pawn which just advanced two squares sits behind the target square
+		pawnSquare := pos.epSquare + 8
+		if pos.turn == white {
+			pawnSquare = pos.epSquare - 8
+		}
+		pc := pos.board.pieces[pawnSquare]
+		if pc == nil || pc.id != pawn || pc.color == pos.turn {
+			return nil, fenError(fen, "no pawn to capture en passant")
+		}
+		pc.enpassantMove = 0
+	}
+	g := newGame(pos.board, colorChoice)
+	g.turn = pos.turn
+	g.castling = pos.castling
+	g.board.key ^= g.stateKey()
+	g.halfmoveClock = pos.halfmoveClock
+	if fen != StartFEN {
+		g.startFEN = fen
+	}
+	g.startPly = 2 * (pos.fullmove - 1)
+	if pos.turn == black {
+		g.startPly += 1
+	}
+	if g.inCheck(otherColor(pos.turn)) {
+		return nil, fenError(fen, "side not to move is in check")
+	}
+	return g, nil
+}
+
+// FEN returns the current position in Forsyth-Edwards Notation
+func (g *Game) FEN() string {
+	pieces := g.board.pieces
+	ranks := make([]string, 0, 8)
+	for rank := 8; rank >= 1; rank-- {
+		rankStr := ""
+		empty := 0
+		for file := 1; file <= 8; file++ {
+			pc := pieces[8*(rank-1)+file-1]
+			if pc == nil {
+				empty += 1
+				continue
+			}
+			if empty > 0 {
+				rankStr += strconv.Itoa(empty)
+				empty = 0
+			}
+			if pc.color == white {
+				rankStr += pieceLetters[pc.id]
+			} else {
+				rankStr += strings.ToLower(pieceLetters[pc.id])
+			}
+		}
+		if empty > 0 {
+			rankStr += strconv.Itoa(empty)
+		}
+		ranks = append(ranks, rankStr)
+	}
+	turn := "w"
+	if g.turn == black {
+		turn = "b"
+	}
+	return fmt.Sprintf("%s %s %s %s %d %d", strings.Join(ranks, "/"), turn,
+		g.castlingRights(), g.enpassantTarget(), g.halfmoveClock,
+		g.MoveNumber())
+}
+
+// castlingRights lists castling rights in FEN notation, like KQkq
+func (g *Game) castlingRights() string {
+	rights := ""
+	for _, right := range "KQkq" {
+		if g.castling&castlingSquares[right].right != 0 {
+			rights += string(right)
+		}
+	}
+	if rights == "" {
+		return "-"
+	}
+	return rights
+}
+
+// enpassantTarget returns the square skipped by a pawn which advanced two
+// squares in the last move, or '-'.
+func (g *Game) enpassantTarget() string {
+	index := g.enpassantSquare()
+	if index == -1 {
+		return "-"
+	}
+	square, _ := toCoordinates(index)
+	return square
+}
+
+// enpassantSquare returns board index of the square skipped by a pawn which
+// advanced two squares in the last move, or -1.
+func (g *Game) enpassantSquare() int {
+	for pawns := g.board.types[pawn]; pawns != 0; pawns &= pawns - 1 {
+		pc := g.board.pieces[bits.TrailingZeros64(pawns)]
+		if pc.enpassantMove != g.moveCount {
+			continue
+		}
+		if pc.color == white {
+			return pc.square - 8
+		}
+		return pc.square + 8
+	}
+	return -1
+}
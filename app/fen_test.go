@@ -0,0 +1,68 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFENRoundTrip(t *testing.T) {
+	fens := []string{
+		StartFEN,
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/8/8/8/8/8/8/R3K2R b Kq - 12 40",
+		"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 2",
+	}
+	for _, fen := range fens {
+		g, err := NewGameFromFEN(fen, black)
+		if err != nil {
+			t.Errorf("NewGameFromFEN(%q) failed: %v", fen, err)
+			continue
+		}
+		if got := g.FEN(); got != fen {
+			t.Errorf("FEN() = %q, want %q", got, fen)
+		}
+	}
+}
+
+func TestFENErrors(t *testing.T) {
+	tests := []struct {
+		fen    string
+		reason string
+	}{
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0", "expected 6 fields"},
+		{"4k3/8/8/8/8/8/4K3 w - - 0 1", "expected 8 ranks"},
+		{"4k3/8/8/8/8/8/8/4K3R w - - 0 1", "too many squares"},
+		{"4k3/8/8/8/8/8/8/4K2 w - - 0 1", "does not have 8 squares"},
+		{"4k3/8/8/8/8/8/8/4K2X w - - 0 1", "unknown piece"},
+		{"4k3/8/8/8/8/8/8/4K2P w - - 0 1", "pawn on first or last rank"},
+		{"4k3/8/8/8/8/8/8/8 w - - 0 1", "exactly one king"},
+		{"4k3/8/8/8/8/8/8/3KK3 w - - 0 1", "exactly one king"},
+		{"4k3/8/8/8/8/8/8/4K3 x - - 0 1", "side to move"},
+		{"4k3/8/8/8/8/8/8/4K3 w X - 0 1", "invalid castling rights"},
+		{"4k3/8/8/8/8/8/8/R3K2R w KK - 0 1", "invalid castling rights"},
+		{"4k3/8/8/8/8/8/8/4K3 w K - 0 1", "without king and rook"},
+		{"4k3/8/8/8/8/8/8/4K3 w - e9 0 1", "Invalid rank"},
+		{"4k3/8/8/8/8/8/8/4K3 w - i6 0 1", "Invalid file"},
+		{"4k3/8/8/8/8/8/8/4K3 w - é 0 1", "Invalid"},
+		{"4k3/8/8/8/8/8/8/4K3 w - e66 0 1", "length"},
+		{"4k3/8/8/8/8/8/8/4K3 w - e3 0 1", "invalid en passant square"},
+		{"4k3/8/8/8/8/8/8/4K3 w - e6 0 1", "no pawn to capture"},
+		{"4k3/8/8/8/8/8/8/4K3 w - - x 1", "invalid halfmove clock"},
+		{"4k3/8/8/8/8/8/8/4K3 w - - -1 1", "invalid halfmove clock"},
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0 0", "invalid fullmove number"},
+		{"4k3/8/8/8/8/8/8/4R1K1 w - - 0 1", "side not to move is in check"},
+	}
+	for _, test := range tests {
+		_, err := NewGameFromFEN(test.fen, black)
+		if err == nil {
+			t.Errorf("NewGameFromFEN(%q) succeeded", test.fen)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("NewGameFromFEN(%q) = %v, want error about %q",
+				test.fen, err, test.reason)
+		}
+	}
+}
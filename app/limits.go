@@ -0,0 +1,111 @@
+package app
+
+import (
+	"time"
+)
+
+// SearchLimits bounds an engine search. Zero values mean no limit. Without
+// any limit the engine searches to the depth set with SetMaxDepth.
+type SearchLimits struct {
+	WhiteTime      time.Duration // Time left on white's clock
+	BlackTime      time.Duration // Time left on black's clock
+	WhiteIncrement time.Duration // Time added to white's clock per move
+	BlackIncrement time.Duration // Time added to black's clock per move
+	MovesToGo      int           // Moves until the next time control
+	MoveTime       time.Duration // Time to search this move
+	Depth          int           // Plies to search
+	Nodes          int           // Board states to evaluate
+	Infinite       bool          // Search until stopped
+}
+
+// maxSearchDepth limits searches bounded by time or nodes only
+const maxSearchDepth = 64
+
+// Moves assumed to be left in the game when the clock has to last till the
+// end of it
+const defaultMovesToGo = 30
+
+// moveOverhead is kept in reserve on each move for time lost outside the
+// search, like communicating with a GUI
+const moveOverhead = 50 * time.Millisecond
+
+// Number of nodes searched between looks at the clock and at whether the
+// search is to stop
+const clockCheckInterval = 1024
+
+// thinkingTime allocates time to a move of given color. The search aims to
+// finish within the optimum time and is aborted once the maximum time is
+// up. Zero durations mean the search is not bound by time.
+func (l SearchLimits) thinkingTime(color int) (optimum, maximum time.Duration) {
+	if l.MoveTime > 0 {
+		return l.MoveTime, l.MoveTime
+	}
+	left, increment := l.WhiteTime, l.WhiteIncrement
+	if color == black {
+		left, increment = l.BlackTime, l.BlackIncrement
+	}
+	if left <= 0 {
+		return 0, 0
+	}
+	movesToGo := l.MovesToGo
+	if movesToGo <= 0 || movesToGo > defaultMovesToGo {
+		movesToGo = defaultMovesToGo
+	}
+	usable := left - moveOverhead
+	if usable < time.Millisecond {
+		usable = time.Millisecond
+	}
+	optimum = usable/time.Duration(movesToGo) + increment*3/4
+	maximum = 3 * optimum
+	if maximum > usable {
+		maximum = usable
+	}
+	if optimum > maximum {
+		optimum = maximum
+	}
+	return optimum, maximum
+}
+
+// depth returns the depth to search up to
+func (l SearchLimits) depth(defaultDepth int) int {
+	switch {
+	case l.Depth > 0:
+		if l.Depth > maxSearchDepth {
+			return maxSearchDepth
+		}
+		return l.Depth
+	case l.MoveTime > 0 || l.WhiteTime > 0 || l.BlackTime > 0 ||
+		l.Nodes > 0 || l.Infinite:
+		return maxSearchDepth
+	}
+	return defaultDepth
+}
+
+// outOfLimits tells whether the search has to be aborted as it ran out of
+// time or nodes or was told to stop. The first iteration always completes
+// so that there is a move to play.
+func (g *Game) outOfLimits() bool {
+	if g.aborted {
+		return true
+	}
+	if g.searchDepth == 1 {
+		return false
+	}
+	if g.limits.Nodes > 0 && g.nodes >= g.limits.Nodes {
+		g.aborted = true
+	}
+	if g.nodes < g.nextClockCheck {
+		return g.aborted
+	}
+	g.nextClockCheck = g.nodes + clockCheckInterval
+	g.publishNodes()
+	select {
+	case <-g.stop:
+		g.aborted = true
+	default:
+	}
+	if g.maxTime > 0 && time.Since(g.searchStart) >= g.maxTime {
+		g.aborted = true
+	}
+	return g.aborted
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"math/bits"
+)
+
+// magic looks up attacks of a sliding piece on a square. Occupied squares
+// relevant to the piece are multiplied by a magic number, which maps every
+// occupancy to an index of a table of attacks without harmful collisions.
+type magic struct {
+	mask    uint64 // Squares whose occupancy affects the attacks
+	number  uint64
+	shift   uint
+	attacks []uint64
+}
+
+var rookMagics, bishopMagics [64]magic
+
+// Seeds of the random numbers tried as magics for squares of each rank.
+// These are known to find magics within a few attempts, keeping start up
+// fast and deterministic.
+var magicSeeds = [8]uint64{728, 10316, 55013, 32803, 12281, 15100, 16645, 255}
+
+// initMagics finds magics for rooks and bishops on every square. Rays must
+// be set up beforehand.
+func initMagics() {
+	for square := 0; square < 64; square++ {
+		rookMagics[square] = findMagic(square, rookDirections, rookRayAttacks)
+		bishopMagics[square] = findMagic(
+			square, bishopDirections, bishopRayAttacks)
+	}
+}
+
+// xorshift generates pseudo random numbers with the xorshift64* algorithm
+type xorshift struct {
+	state uint64
+}
+
+func (x *xorshift) next() uint64 {
+	x.state ^= x.state >> 12
+	x.state ^= x.state << 25
+	x.state ^= x.state >> 27
+	return x.state * 2685821657736338717
+}
+
+// sparse returns a random number with about one in eight bits set
+func (x *xorshift) sparse() uint64 {
+	return x.next() & x.next() & x.next()
+}
+
+func (m *magic) index(occupied uint64) uint64 {
+	return ((occupied & m.mask) * m.number) >> m.shift
+}
+
+// findMagic tries random sparse numbers until one maps all occupancies of
+// the squares a slider can reach from given square to the right attacks.
+// Attacks are computed by walking the rays while looking for one.
+func findMagic(
+	square int, directions []int,
+	rayAttacks func(int, uint64) uint64) magic {
+	var mask uint64
+	for _, dir := range directions {
+		ray := rays[dir][square]
+		if ray == 0 {
+			continue
+		}
+		// Last square of a ray attacks the same whether occupied or not
+		last := 63 - bits.LeadingZeros64(ray)
+		if dir == south || dir == west || dir == southEast ||
+			dir == southWest {
+			last = bits.TrailingZeros64(ray)
+		}
+		mask |= ray &^ (1 << uint(last))
+	}
+	size := 1 << uint(bits.OnesCount64(mask))
+	occupancies := make([]uint64, 0, size)
+	references := make([]uint64, 0, size)
+	// Enumerate all subsets of the mask
+	for subset := uint64(0); ; {
+		occupancies = append(occupancies, subset)
+		references = append(references, rayAttacks(square, subset))
+		subset = (subset - mask) & mask
+		if subset == 0 {
+			break
+		}
+	}
+	m := magic{
+		mask:    mask,
+		shift:   uint(64 - bits.OnesCount64(mask)),
+		attacks: make([]uint64, size),
+	}
+	rank, _ := getRankFile(square)
+	random := &xorshift{magicSeeds[rank-1]}
+	// Attempt in which each table entry was last filled
+	filled := make([]int, size)
+	for attempt := 1; ; attempt++ {
+		m.number = random.sparse()
+		if bits.OnesCount64((mask*m.number)>>56) < 6 {
+			// Too few high bits to spread occupancies across the table
+			continue
+		}
+		found := true
+		for i, occupied := range occupancies {
+			index := m.index(occupied)
+			if filled[index] != attempt {
+				filled[index] = attempt
+				m.attacks[index] = references[i]
+			} else if m.attacks[index] != references[i] {
+				found = false
+				break
+			}
+		}
+		if found {
+			return m
+		}
+	}
+}
+
+func rookAttacks(square int, occupied uint64) uint64 {
+	m := &rookMagics[square]
+	return m.attacks[m.index(occupied)]
+}
+
+func bishopAttacks(square int, occupied uint64) uint64 {
+	m := &bishopMagics[square]
+	return m.attacks[m.index(occupied)]
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestMagicAttacks(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		// Sparse and dense boards alike
+		occupied := rng.Uint64() & rng.Uint64()
+		if i%2 == 1 {
+			occupied = rng.Uint64() | rng.Uint64()
+		}
+		for square := 0; square < 64; square++ {
+			want := rookRayAttacks(square, occupied)
+			if got := rookAttacks(square, occupied); got != want {
+				t.Fatalf("rookAttacks(%d, %#x) = %#x, want %#x",
+					square, occupied, got, want)
+			}
+			want = bishopRayAttacks(square, occupied)
+			if got := bishopAttacks(square, occupied); got != want {
+				t.Fatalf("bishopAttacks(%d, %#x) = %#x, want %#x",
+					square, occupied, got, want)
+			}
+		}
+	}
+}
+
+// Positions whose occupied squares are used to time attack generation
+var benchmarkFENs = []string{
+	StartFEN,
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+}
+
+func benchmarkGames(b *testing.B) []*Game {
+	games := make([]*Game, 0, len(benchmarkFENs))
+	for _, fen := range benchmarkFENs {
+		g, err := NewGameFromFEN(fen, white)
+		if err != nil {
+			b.Fatal(err)
+		}
+		games = append(games, g)
+	}
+	return games
+}
+
+// benchmarkAttacks times attacks of a piece on every square of the
+// benchmark positions
+func benchmarkAttacks(b *testing.B, attacksOf func(int, uint64) uint64) {
+	occupancies := make([]uint64, 0, len(benchmarkFENs))
+	for _, g := range benchmarkGames(b) {
+		occupancies = append(occupancies, g.board.occupied())
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, occupied := range occupancies {
+			for square := 0; square < 64; square++ {
+				attacksOf(square, occupied)
+			}
+		}
+	}
+}
+
+func BenchmarkRookAttacks(b *testing.B) {
+	benchmarkAttacks(b, rookAttacks)
+}
+
+func BenchmarkRookRayAttacks(b *testing.B) {
+	benchmarkAttacks(b, rookRayAttacks)
+}
+
+func BenchmarkBishopAttacks(b *testing.B) {
+	benchmarkAttacks(b, bishopAttacks)
+}
+
+func BenchmarkBishopRayAttacks(b *testing.B) {
+	benchmarkAttacks(b, bishopRayAttacks)
+}
+
+func BenchmarkGenerateMoves(b *testing.B) {
+	games := benchmarkGames(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, g := range games {
+			g.generateMoves(true)
+			g.generateMoves(false)
+		}
+	}
+}
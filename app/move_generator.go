@@ -2,6 +2,7 @@ package app
 
 import (
 	"math"
+	"math/bits"
 )
 
 func getRankFile(boardIndex int) (int, int) {
@@ -15,373 +16,203 @@ func getRankFile(boardIndex int) (int, int) {
 	return rank, file
 }
 
-func newBoardMove(
+func (g *Game) newBoardMove(
 	from, to, castlingFrom, castlingTo, promotedPc int,
-	attacks *uint) boardMove {
+	attacks *uint64) boardMove {
 	*attacks |= 1 << to
 	return boardMove{
 		From:         from,
 		To:           to,
 		castlingFrom: castlingFrom,
 		castlingTo:   castlingTo,
-		captured:     game.board.pieces[to],
+		captured:     g.board.pieces[to],
 		PromotedPc:   promotedPc,
 	}
 }
 
-func canCastle(kingFrom, kingTo int) bool {
-	pieces := game.board.pieces
-	if pieces[kingFrom] == nil || pieces[kingFrom].moveCount > 1 {
-		// king already moved
+// enpassantMove returns en passant capture of the pawn on side square by
+// the pawn moving from given position, if the former just advanced two
+// squares.
+func (g *Game) enpassantMove(
+	pc *piece, from, to, side int, attacks *uint64) (boardMove, bool) {
+	sidePc := g.board.pieces[side]
+	if sidePc == nil || sidePc.id != pawn || sidePc.color == pc.color ||
+		sidePc.enpassantMove != g.moveCount {
+		return boardMove{}, false
+	}
+	*attacks |= 1 << to
+	return boardMove{
+		From:         from,
+		To:           to,
+		castlingFrom: -1,
+		castlingTo:   -1,
+		captured:     sidePc,
+		PromotedPc:   -1,
+		enpassant:    true,
+	}, true
+}
+
+func (g *Game) canCastle(kingFrom, kingTo int) bool {
+	pieces := g.board.pieces
+	color := pieces[kingFrom].color
+	right, rookPos, between := whiteKingSide, kingFrom+3, []int{1, 2}
+	if kingTo < kingFrom {
+		right, rookPos, between = whiteQueenSide, kingFrom-4, []int{-1, -2, -3}
+	}
+	if color == black {
+		// Black rights are shifted left by 2 bits
+		right <<= 2
+	}
+	if g.castling&right == 0 {
+		// king or rook already moved
 		return false
 	}
-	if kingTo > kingFrom {
-		// king side castling
-		rookPos := kingFrom + 3
-		if pieces[rookPos] == nil || pieces[rookPos].moveCount > 1 {
-			// rook moved
-			return false
-		}
-		if pieces[kingFrom+1] != nil || pieces[kingFrom+2] != nil {
+	rookPc := pieces[rookPos]
+	if rookPc == nil || rookPc.id != rook || rookPc.color != color {
+		return false
+	}
+	for _, offset := range between {
+		if pieces[kingFrom+offset] != nil {
 			// pieces in between
 			return false
 		}
-		return true
-	}
-	// queen side castling
-	rookPos := kingFrom - 4
-	if pieces[rookPos] == nil || pieces[rookPos].moveCount > 1 {
-		// rook moved
-		return false
-	}
-	if pieces[kingFrom-1] != nil || pieces[kingFrom-2] != nil ||
-		pieces[kingFrom-3] != nil {
-		// pieces in between
-		return false
 	}
 	return true
-
 }
 
-func kingMoves(piece *piece) ([]boardMove, uint) {
-	moves := make([]boardMove, 0)
-	var attacks uint
-	pos := int(math.Log2(float64(piece.position)))
-	rank, file := getRankFile(pos)
-	if file >= 2 { // Left
-		moves = append(moves, newBoardMove(pos, pos-1, -1, -1, -1, &attacks))
-	}
-	if file <= 7 { // Right
-		moves = append(moves, newBoardMove(pos, pos+1, -1, -1, -1, &attacks))
-	}
-	if rank <= 7 { // Up
-		moves = append(moves, newBoardMove(pos, pos+8, -1, -1, -1, &attacks))
-	}
-	if rank >= 2 { // Down
-		moves = append(moves, newBoardMove(pos, pos-8, -1, -1, -1, &attacks))
-	}
-	if file >= 2 && rank <= 7 { // Upper diagonal left
-		moves = append(moves, newBoardMove(pos, pos+7, -1, -1, -1, &attacks))
+// isAttacked tells whether any piece of given color attacks the square
+func (g *Game) isAttacked(index, color int) bool {
+	brd := g.board
+	attackers := brd.colors[color]
+	occupied := brd.occupied()
+	queens := brd.types[queen]
+	// Pawns attack diagonally forward, so look backward from the square
+	if pawnAttacks[otherColor(color)][index]&attackers&brd.types[pawn] != 0 {
+		return true
 	}
-	if file <= 7 && rank <= 7 { // Upper diagonal right
-		moves = append(moves, newBoardMove(pos, pos+9, -1, -1, -1, &attacks))
+	if knightAttacks[index]&attackers&brd.types[knight] != 0 {
+		return true
 	}
-	if file >= 2 && rank >= 2 { // Lower diagonal left
-		moves = append(moves, newBoardMove(pos, pos-9, -1, -1, -1, &attacks))
+	if kingAttacks[index]&attackers&brd.types[king] != 0 {
+		return true
 	}
-	if file <= 7 && rank >= 2 { // Lower diagonal right
-		moves = append(moves, newBoardMove(pos, pos-7, -1, -1, -1, &attacks))
+	if rookAttacks(index, occupied)&attackers&(brd.types[rook]|queens) != 0 {
+		return true
 	}
-	if piece.moveCount > 0 {
+	return bishopAttacks(index, occupied)&attackers&
+		(brd.types[bishop]|queens) != 0
+}
+
+// targetMoves appends moves from given square to each of the target squares
+func (g *Game) targetMoves(
+	moves []boardMove, from int, targets uint64,
+	attacks *uint64) []boardMove {
+	for ; targets != 0; targets &= targets - 1 {
+		to := bits.TrailingZeros64(targets)
+		moves = append(moves, g.newBoardMove(from, to, -1, -1, -1, attacks))
+	}
+	return moves
+}
+
+func (g *Game) kingMoves(piece *piece) ([]boardMove, uint64) {
+	var attacks uint64
+	pos := piece.square
+	targets := kingAttacks[pos] &^ g.board.colors[piece.color]
+	moves := g.targetMoves(make([]boardMove, 0, 8), pos, targets, &attacks)
+	if g.castling&colorCastling[piece.color] == 0 {
 		return moves, attacks
 	}
 	// Check king side castling
-	if canCastle(pos, pos+2) {
+	if g.canCastle(pos, pos+2) {
 		moves = append(
-			moves, newBoardMove(pos, pos+2, pos+3, pos+1, -1, &attacks))
+			moves, g.newBoardMove(pos, pos+2, pos+3, pos+1, -1, &attacks))
 	}
 	// Check queen side castling
-	if canCastle(pos, pos-2) {
+	if g.canCastle(pos, pos-2) {
 		moves = append(
-			moves, newBoardMove(pos, pos-2, pos-4, pos-1, -1, &attacks))
+			moves, g.newBoardMove(pos, pos-2, pos-4, pos-1, -1, &attacks))
 	}
 	return moves, attacks
 }
 
-func queenMoves(piece *piece) ([]boardMove, uint) {
-	rmoves, rattacks := rookMoves(piece)
-	bmoves, battacks := bishopMoves(piece)
-	return append(rmoves, bmoves...), rattacks | battacks
-}
-
-func rookMoves(piece *piece) ([]boardMove, uint) {
-	moves := make([]boardMove, 0)
-	var attacks uint
-	pos := int(math.Log2(float64(piece.position)))
-	rank, file := getRankFile(pos)
-	pieces := game.board.pieces
-	// Traverse file upwards until obstructed
-	for p, r := pos+8, rank+1; r <= 8; {
-		moves = append(moves, newBoardMove(pos, p, -1, -1, -1, &attacks))
-		if pieces[p] != nil {
-			break
-		}
-		p += 8
-		r += 1
-	}
-	// Traverse file downwards until obstructed
-	for p, r := pos-8, rank-1; r >= 1; {
-		moves = append(moves, newBoardMove(pos, p, -1, -1, -1, &attacks))
-		if pieces[p] != nil {
-			break
-		}
-		p -= 8
-		r -= 1
-	}
-	// Traverse rank left until obstructed
-	for p, f := pos-1, file-1; f >= 1; {
-		moves = append(moves, newBoardMove(pos, p, -1, -1, -1, &attacks))
-		if pieces[p] != nil {
-			break
-		}
-		p -= 1
-		f -= 1
-	}
-	// Traverse rank right until obstructed
-	for p, f := pos+1, file+1; f <= 8; {
-		moves = append(moves, newBoardMove(pos, p, -1, -1, -1, &attacks))
-		if pieces[p] != nil {
-			break
-		}
-		p += 1
-		f += 1
-	}
+func (g *Game) queenMoves(piece *piece) ([]boardMove, uint64) {
+	var attacks uint64
+	pos := piece.square
+	occupied := g.board.occupied()
+	targets := (rookAttacks(pos, occupied) | bishopAttacks(pos, occupied)) &^
+		g.board.colors[piece.color]
+	moves := g.targetMoves(make([]boardMove, 0, 16), pos, targets, &attacks)
 	return moves, attacks
 }
 
-func bishopMoves(piece *piece) ([]boardMove, uint) {
-	moves := make([]boardMove, 0)
-	var attacks uint
-	pos := int(math.Log2(float64(piece.position)))
-	rank, file := getRankFile(pos)
-	pieces := game.board.pieces
-	// Traverse top right diagonal until obstructed
-	p, f, r := pos+8+1, file+1, rank+1
-	for f <= 8 && r <= 8 {
-		moves = append(moves, newBoardMove(pos, p, -1, -1, -1, &attacks))
-		if pieces[p] != nil {
-			break
-		}
-		p += 8 + 1
-		f += 1
-		r += 1
-	}
-	// Traverse bottom right diagonal until obstructed
-	p, f, r = pos-8+1, file+1, rank-1
-	for f <= 8 && r >= 1 {
-		moves = append(moves, newBoardMove(pos, p, -1, -1, -1, &attacks))
-		if pieces[p] != nil {
-			break
-		}
-		p = p - 8 + 1
-		f += 1
-		r -= 1
-	}
-	// Traverse bottom left diagonal until obstructed
-	p, f, r = pos-8-1, file-1, rank-1
-	for f >= 1 && r >= 1 {
-		moves = append(moves, newBoardMove(pos, p, -1, -1, -1, &attacks))
-		if pieces[p] != nil {
-			break
-		}
-		p = p - 8 - 1
-		f -= 1
-		r -= 1
-	}
-	// Traverse top left diagonal until obstructed
-	p, f, r = pos+8-1, file-1, rank+1
-	for f >= 1 && r <= 8 {
-		moves = append(moves, newBoardMove(pos, p, -1, -1, -1, &attacks))
-		if pieces[p] != nil {
-			break
-		}
-		p += 8 - 1
-		f -= 1
-		r += 1
-	}
-	return moves, attacks
-}
-
-func knightMoves(piece *piece) ([]boardMove, uint) {
-	moves := make([]boardMove, 0)
-	var attacks uint
-	pos := int(math.Log2(float64(piece.position)))
-	rank, file := getRankFile(pos)
-	// Clock wise moves from top right
-	if file <= 7 && rank <= 6 {
-		moves = append(moves, newBoardMove(pos, pos+8+9, -1, -1, -1, &attacks))
-	}
-	if file <= 6 && rank <= 7 {
-		moves = append(moves, newBoardMove(pos, pos+8+2, -1, -1, -1, &attacks))
-	}
-	if file <= 6 && rank >= 2 {
-		moves = append(moves, newBoardMove(pos, pos-6, -1, -1, -1, &attacks))
-	}
-	if file <= 7 && rank >= 3 {
-		moves = append(moves, newBoardMove(pos, pos-8-7, -1, -1, -1, &attacks))
-	}
-	if file >= 2 && rank >= 3 {
-		moves = append(moves, newBoardMove(pos, pos-8-9, -1, -1, -1, &attacks))
-	}
-	if file >= 3 && rank >= 2 {
-		moves = append(moves, newBoardMove(pos, pos-8-2, -1, -1, -1, &attacks))
-	}
-	if file >= 3 && rank <= 7 {
-		moves = append(moves, newBoardMove(pos, pos+6, -1, -1, -1, &attacks))
-	}
-	if file >= 2 && rank <= 6 {
-		moves = append(moves, newBoardMove(pos, pos+8+7, -1, -1, -1, &attacks))
-	}
-	return moves, attacks
-}
-
-func whitePromotionMoves(piece *piece, file, pos int) ([]boardMove, uint) {
-	moves := make([]boardMove, 0)
-	var attacks uint
-	pieces := game.board.pieces
-	if pieces[pos+8] == nil { // Up
-		for _, promPiece := range promotablePieces {
-			moves = append(
-				moves, newBoardMove(pos, pos+8, -1, -1, promPiece, &attacks))
-		}
-	}
-	if file >= 2 && pieces[pos+7] != nil { // Upper diagonal left
-		for _, promPiece := range promotablePieces {
-			moves = append(
-				moves, newBoardMove(pos, pos+7, -1, -1, promPiece, &attacks))
-		}
-	}
-	if file <= 7 && pieces[pos+9] != nil { // Upper diagonal right
-		for _, promPiece := range promotablePieces {
-			moves = append(
-				moves, newBoardMove(pos, pos+9, -1, -1, promPiece, &attacks))
-		}
-	}
+func (g *Game) rookMoves(piece *piece) ([]boardMove, uint64) {
+	var attacks uint64
+	pos := piece.square
+	targets := rookAttacks(pos, g.board.occupied()) &^
+		g.board.colors[piece.color]
+	moves := g.targetMoves(make([]boardMove, 0, 8), pos, targets, &attacks)
 	return moves, attacks
 }
 
-func whitePawnMoves(piece *piece) ([]boardMove, uint) {
-	pos := int(math.Log2(float64(piece.position)))
-	rank, file := getRankFile(pos)
-	if rank == 7 {
-		return whitePromotionMoves(piece, file, pos)
-	}
-	moves := make([]boardMove, 0)
-	var attacks uint
-	pieces := game.board.pieces
-	if rank <= 7 && pieces[pos+8] == nil { // Up
-		moves = append(moves, newBoardMove(pos, pos+8, -1, -1, -1, &attacks))
-		if rank == 2 && pieces[pos+16] == nil { // Up twice
-			moves = append(
-				moves, newBoardMove(pos, pos+16, -1, -1, -1, &attacks))
-		}
-	}
-	if file >= 2 && rank < 8 {
-		// Upper diagonal left
-		if pieces[pos+7] != nil {
-			moves = append(
-				moves, newBoardMove(pos, pos+7, -1, -1, -1, &attacks))
-		} else {
-			sidePc := pieces[pos-1]
-			if sidePc != nil && sidePc.enpassantMove == game.moveCount {
-				moves = append(
-					moves, newBoardMove(pos, pos+7, -1, -1, -1, &attacks))
-			}
-		}
-	}
-	if file <= 7 && rank < 8 {
-		// Upper diagonal right
-		if pieces[pos+9] != nil {
-			moves = append(
-				moves, newBoardMove(pos, pos+9, -1, -1, -1, &attacks))
-		} else {
-			sidePc := pieces[pos+1]
-			if sidePc != nil && sidePc.enpassantMove == game.moveCount {
-				moves = append(
-					moves, newBoardMove(pos, pos+9, -1, -1, -1, &attacks))
-			}
-		}
-	}
+func (g *Game) bishopMoves(piece *piece) ([]boardMove, uint64) {
+	var attacks uint64
+	pos := piece.square
+	targets := bishopAttacks(pos, g.board.occupied()) &^
+		g.board.colors[piece.color]
+	moves := g.targetMoves(make([]boardMove, 0, 8), pos, targets, &attacks)
 	return moves, attacks
 }
 
-func blackPromotionMoves(piece *piece, file, pos int) ([]boardMove, uint) {
-	moves := make([]boardMove, 0)
-	var attacks uint
-	pieces := game.board.pieces
-	// Color is black so we need to move reverse in terms of board numbering
-	if pieces[pos-8] == nil { // Down
-		for _, promPiece := range promotablePieces {
-			moves = append(
-				moves, newBoardMove(pos, pos-8, -1, -1, promPiece, &attacks))
-		}
-	}
-	if file >= 2 && pieces[pos-9] != nil { // Lower diagonal left
-		for _, promPiece := range promotablePieces {
-			moves = append(
-				moves, newBoardMove(pos, pos-9, -1, -1, promPiece, &attacks))
-		}
-	}
-	if file <= 7 && pieces[pos-7] != nil { // Lower diagonal right
-		for _, promPiece := range promotablePieces {
-			moves = append(
-				moves, newBoardMove(pos, pos-7, -1, -1, promPiece, &attacks))
-		}
-	}
+func (g *Game) knightMoves(piece *piece) ([]boardMove, uint64) {
+	var attacks uint64
+	pos := piece.square
+	targets := knightAttacks[pos] &^ g.board.colors[piece.color]
+	moves := g.targetMoves(make([]boardMove, 0, 8), pos, targets, &attacks)
 	return moves, attacks
 }
 
-func blackPawnMoves(piece *piece) ([]boardMove, uint) {
-	pos := int(math.Log2(float64(piece.position)))
+func (g *Game) pawnMoves(piece *piece) ([]boardMove, uint64) {
+	moves := make([]boardMove, 0, 4)
+	var attacks uint64
+	pos := piece.square
 	rank, file := getRankFile(pos)
-	if rank == 2 {
-		return blackPromotionMoves(piece, file, pos)
-	}
-	moves := make([]boardMove, 0)
-	var attacks uint
-	pieces := game.board.pieces
-	// Color is black so we need to move reverse in terms of board numbering
-	if rank >= 2 && pieces[pos-8] == nil { // Down
-		moves = append(moves, newBoardMove(pos, pos-8, -1, -1, -1, &attacks))
-		if rank == 7 && pieces[pos-16] == nil { // Down twice
+	// Black pawns move in reverse in terms of board numbering
+	forward, startRank, promotionRank := 8, 2, 7
+	if piece.color == black {
+		forward, startRank, promotionRank = -8, 7, 2
+	}
+	occupied := g.board.occupied()
+	targets := pawnAttacks[piece.color][pos] &
+		g.board.colors[otherColor(piece.color)]
+	if occupied&(1<<uint(pos+forward)) == 0 {
+		targets |= 1 << uint(pos+forward)
+		if rank == startRank && occupied&(1<<uint(pos+2*forward)) == 0 {
+			targets |= 1 << uint(pos+2*forward)
+		}
+	}
+	promotedPcs := []int{-1}
+	if rank == promotionRank {
+		promotedPcs = promotablePieces
+	}
+	for ; targets != 0; targets &= targets - 1 {
+		to := bits.TrailingZeros64(targets)
+		for _, promPiece := range promotedPcs {
 			moves = append(
-				moves, newBoardMove(pos, pos-16, -1, -1, -1, &attacks))
+				moves, g.newBoardMove(pos, to, -1, -1, promPiece, &attacks))
 		}
 	}
-	if file >= 2 && rank >= 2 {
-		// Lower diagonal left
-		if pieces[pos-9] != nil {
-			moves = append(
-				moves, newBoardMove(pos, pos-9, -1, -1, -1, &attacks))
-		} else {
-			sidePc := pieces[pos-1]
-			if sidePc != nil && sidePc.enpassantMove == game.moveCount {
-				moves = append(
-					moves, newBoardMove(pos, pos-9, -1, -1, -1, &attacks))
-			}
+	if file >= 2 {
+		// Diagonal left
+		mv, ok := g.enpassantMove(piece, pos, pos+forward-1, pos-1, &attacks)
+		if ok {
+			moves = append(moves, mv)
 		}
 	}
-	if file <= 7 && rank >= 2 {
-		// Lower diagonal right
-		if pieces[pos-7] != nil {
-			moves = append(
-				moves, newBoardMove(pos, pos-7, -1, -1, -1, &attacks))
-		} else {
-			sidePc := pieces[pos+1]
-			if sidePc != nil && sidePc.enpassantMove == game.moveCount {
-				moves = append(
-					moves, newBoardMove(pos, pos-7, -1, -1, -1, &attacks))
-			}
+	if file <= 7 {
+		// Diagonal right
+		mv, ok := g.enpassantMove(piece, pos, pos+forward+1, pos+1, &attacks)
+		if ok {
+			moves = append(moves, mv)
 		}
 	}
 	return moves, attacks
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"testing"
+)
+
+// perft counts the legal move sequences of given number of plies from the
+// current position
+func perft(g *Game, depth int) int {
+	if depth == 0 {
+		return 1
+	}
+	moves, _ := g.generateMoves(g.MyTurn())
+	count := 0
+	for _, move := range moves {
+		if !g.isMoveLegal(move) {
+			continue
+		}
+		g.alterPosition(move)
+		count += perft(g, depth-1)
+		g.undoMove(move)
+	}
+	return count
+}
+
+// Node counts published on the Chess Programming Wiki
+func TestPerft(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		counts []int // Node counts at depth 1 and beyond
+	}{
+		{"start position", StartFEN, []int{20, 400, 8902, 197281}},
+		{
+			"kiwipete",
+			"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R " +
+				"w KQkq - 0 1",
+			[]int{48, 2039, 97862},
+		},
+		{
+			"en passant",
+			"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+			[]int{14, 191, 2812, 43238, 674624},
+		},
+		{
+			"promotions",
+			"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 " +
+				"w kq - 0 1",
+			[]int{6, 264, 9467, 422333},
+		},
+		{
+			"promotion with check",
+			"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+			[]int{44, 1486, 62379},
+		},
+	}
+	for _, test := range tests {
+		g, err := NewGameFromFEN(test.fen, black)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, want := range test.counts {
+			if got := perft(g, i+1); got != want {
+				t.Errorf("%s: perft(%d) = %d, want %d", test.name, i+1,
+					got, want)
+			}
+		}
+		if got := g.FEN(); got != test.fen {
+			t.Errorf("%s: position after perft is %s", test.name, got)
+		}
+	}
+}
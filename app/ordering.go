@@ -0,0 +1,127 @@
+package app
+
+import (
+	"sort"
+)
+
+// Move ordering: the earlier a good move is searched, the sooner the search
+// cuts off the remaining moves. Captures and promotions come first, the
+// ones winning most valuable pieces with least valuable ones first
+// (MVV-LVA), then killer moves, which caused a cutoff at the same ply in a
+// sibling position, then the move which last refuted the opponent's
+// previous move, and then the other quiet moves by their history score,
+// which grows each time the move causes a cutoff. Captures losing material
+// by static exchange evaluation come last. The hash move and the principal
+// variation move are put in front of all of them by the search.
+
+// Scores of move classes, each above the scores of all later classes
+const (
+	noisyScore   = 1 << 24
+	killerScore  = 1 << 22 // Second killer scores one less
+	counterScore = 1<<22 - 2
+	losingScore  = -noisyScore
+)
+
+// orderedMoves sorts moves by descending score
+type orderedMoves struct {
+	moves  []boardMove
+	scores []int
+}
+
+func (om orderedMoves) Len() int {
+	return len(om.moves)
+}
+
+func (om orderedMoves) Less(i, j int) bool {
+	return om.scores[i] > om.scores[j]
+}
+
+func (om orderedMoves) Swap(i, j int) {
+	om.moves[i], om.moves[j] = om.moves[j], om.moves[i]
+	om.scores[i], om.scores[j] = om.scores[j], om.scores[i]
+}
+
+// orderMoves sorts the moves of the position at given ply so that the ones
+// most likely to cause a cutoff come first
+func (g *Game) orderMoves(moves []boardMove, ply int) {
+	var counter boardMove
+	if ply > 0 {
+		prev := g.searchPath[ply-1]
+		counter = g.counterMoves[prev.From][prev.To]
+	}
+	scores := make([]int, len(moves))
+	for i, move := range moves {
+		switch {
+		case isNoisy(move) && g.losing(move):
+			scores[i] = losingScore + g.mvvLva(move)
+		case isNoisy(move):
+			scores[i] = noisyScore + g.mvvLva(move)
+		case move == g.killers[ply][0]:
+			scores[i] = killerScore
+		case move == g.killers[ply][1]:
+			scores[i] = killerScore - 1
+		case move == counter:
+			scores[i] = counterScore
+		default:
+			pc := g.board.pieces[move.From]
+			scores[i] = g.historyScores[pc.color][move.From][move.To]
+		}
+	}
+	sort.Stable(orderedMoves{moves, scores})
+}
+
+// isNoisy tells whether a move changes material, as captures and
+// promotions do
+func isNoisy(move boardMove) bool {
+	return move.captured != nil || move.PromotedPc > 0
+}
+
+// mvvLva scores a capture or promotion by the value of the piece won,
+// breaking ties by preferring the least valuable piece moving. Piece types
+// are numbered from the most valuable king to the least valuable pawn.
+func (g *Game) mvvLva(move boardMove) int {
+	score := g.board.pieces[move.From].id
+	if move.captured != nil {
+		score += (pawn + 1 - move.captured.id) * (pawn + 1)
+	}
+	if move.PromotedPc > 0 {
+		score += (pawn - move.PromotedPc) * (pawn + 1)
+	}
+	return score
+}
+
+// recordCutoff remembers a quiet move which caused a cutoff at given ply
+// with given number of plies left to search, so that it is tried early in
+// similar positions
+func (g *Game) recordCutoff(ply, remaining int, move boardMove) {
+	if isNoisy(move) {
+		return
+	}
+	if g.killers[ply][0] != move {
+		g.killers[ply][1] = g.killers[ply][0]
+		g.killers[ply][0] = move
+	}
+	if ply > 0 {
+		prev := g.searchPath[ply-1]
+		g.counterMoves[prev.From][prev.To] = move
+	}
+	// Cutoffs far from the leaves prune more, so they weigh more
+	color := g.board.pieces[move.From].color
+	score := &g.historyScores[color][move.From][move.To]
+	*score += remaining * remaining
+	if *score >= killerScore/2 {
+		g.ageHistory()
+	}
+}
+
+// ageHistory halves history scores so that they stay below the scores of
+// killer moves and recent cutoffs outweigh old ones
+func (g *Game) ageHistory() {
+	for color := range g.historyScores {
+		for from := range g.historyScores[color] {
+			for to := range g.historyScores[color][from] {
+				g.historyScores[color][from][to] /= 2
+			}
+		}
+	}
+}
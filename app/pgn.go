@@ -0,0 +1,135 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Seven Tag Roster in the order mandated by the PGN standard
+var sevenTagRoster = []string{
+	"Event", "Site", "Date", "Round", "White", "Black", "Result",
+}
+
+// maxLineLength of movetext lines in exported PGN
+const maxLineLength = 79
+
+// PGN returns the game played so far in Portable Game Notation. Given tags
+// override or add to the default tag values. Engine evaluations and think
+// times are included as move comments if comments is set.
+func (g *Game) PGN(tags map[string]string, comments bool) string {
+	values := map[string]string{
+		"Event":  "Casual game",
+		"Site":   "?",
+		"Date":   g.started.Format("2006.01.02"),
+		"Round":  "-",
+		"White":  "?",
+		"Black":  "?",
+		"Result": g.gameResult(),
+	}
+	if g.myColor == white {
+		values["White"] = "heisenberg"
+	} else {
+		values["Black"] = "heisenberg"
+	}
+	if g.startFEN != "" {
+		values["SetUp"] = "1"
+		values["FEN"] = g.startFEN
+	}
+	for name, value := range tags {
+		values[name] = value
+	}
+	result := values["Result"]
+	var sb strings.Builder
+	for _, name := range sevenTagRoster {
+		writeTag(&sb, name, values[name])
+		delete(values, name)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeTag(&sb, name, values[name])
+	}
+	sb.WriteString("\n")
+	sb.WriteString(g.movetext(comments, result))
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+func writeTag(sb *strings.Builder, name, value string) {
+	value = strings.Replace(value, "\\", "\\\\", -1)
+	value = strings.Replace(value, "\"", "\\\"", -1)
+	fmt.Fprintf(sb, "[%s \"%s\"]\n", name, value)
+}
+
+// movetext renders the moves played, wrapped to lines of limited length
+func (g *Game) movetext(comments bool, result string) string {
+	tokens := make([]string, 0, 2*len(g.history)+1)
+	needNumber := true
+	for i, played := range g.history {
+		ply := g.startPly + i
+		moveNumber := ply/2 + 1
+		if ply%2 == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d.", moveNumber))
+		} else if needNumber {
+			tokens = append(tokens, fmt.Sprintf("%d...", moveNumber))
+		}
+		tokens = append(tokens, played.san)
+		needNumber = false
+		if comments && played.comment != "" {
+			tokens = append(tokens, "{"+played.comment+"}")
+			// Black move following a comment repeats the move number
+			needNumber = true
+		}
+	}
+	tokens = append(tokens, result)
+	lines := make([]string, 0)
+	line := ""
+	for _, token := range tokens {
+		if line != "" && len(line)+1+len(token) > maxLineLength {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += token
+	}
+	lines = append(lines, line)
+	return strings.Join(lines, "\n")
+}
+
+// gameResult returns the result of current game as used in PGN
+func (g *Game) gameResult() string {
+	switch g.Status() {
+	case InProgress:
+		return "*"
+	case Win, Lost:
+		// Side to move is checkmated
+		if g.turn == white {
+			return "0-1"
+		}
+		return "1-0"
+	}
+	return "1/2-1/2"
+}
+
+// searchComment describes an engine search as a PGN move comment holding
+// the evaluation from white's perspective, like 0.35 or #-3 for white
+// getting mated in 3 moves, and the elapsed move time.
+func (g *Game) searchComment(info SearchInfo) string {
+	score, mate := info.Score, info.Mate
+	if g.myColor == black {
+		score, mate = -score, -mate
+	}
+	eval := fmt.Sprintf("%.2f", score)
+	if mate != 0 {
+		eval = fmt.Sprintf("#%d", mate)
+	}
+	seconds := int(info.Duration.Seconds())
+	return fmt.Sprintf("[%%eval %s] [%%emt %d:%02d:%02d]",
+		eval, seconds/3600, seconds/60%60, seconds%60)
+}
@@ -0,0 +1,397 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// PGNGame is a game read from Portable Game Notation
+type PGNGame struct {
+	Tags      map[string]string
+	Comment   string    // Comment preceding the first move
+	Moves     []PGNMove // Main line of the game
+	Result    string
+	Positions []string // FEN before the first move and after each move
+}
+
+// PGNMove is a move of a PGN game along with its annotations
+type PGNMove struct {
+	SAN        string
+	Move       UserMove
+	Comment    string
+	NAGs       []int       // Numeric annotation glyphs like $1 for '!'
+	Variations [][]PGNMove // Alternatives to this move
+}
+
+// Move suffix annotations and their equivalent numeric annotation glyphs
+var suffixNAGs = map[string]int{
+	"!":  1,
+	"?":  2,
+	"!!": 3,
+	"??": 4,
+	"!?": 5,
+	"?!": 6,
+}
+
+var pgnResults = map[string]bool{
+	"1-0":     true,
+	"0-1":     true,
+	"1/2-1/2": true,
+	"*":       true,
+}
+
+// pgnReader splits PGN text into tokens
+type pgnReader struct {
+	text string
+	pos  int
+}
+
+func pgnError(reason string, args ...interface{}) error {
+	errMsg := fmt.Sprintf(reason, args...)
+	return errors.New("Invalid PGN: " + errMsg)
+}
+
+// ParsePGN reads all games from PGN text. Moves of every game, variations
+// included, are validated by replaying them on a board.
+func ParsePGN(r io.Reader) ([]*PGNGame, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	reader := &pgnReader{text: string(data)}
+	games := make([]*PGNGame, 0)
+	for {
+		reader.skipSpace()
+		if reader.pos >= len(reader.text) {
+			return games, nil
+		}
+		pgnGame, err := reader.readGame()
+		if err != nil {
+			return nil, err
+		}
+		err = pgnGame.replay()
+		if err != nil {
+			errMsg := fmt.Sprintf("Game %d: %v", len(games)+1, err)
+			return nil, errors.New(errMsg)
+		}
+		games = append(games, pgnGame)
+	}
+}
+
+// skipSpace skips white space and escaped lines starting with '%'
+func (r *pgnReader) skipSpace() {
+	for r.pos < len(r.text) {
+		ch := r.text[r.pos]
+		if ch == '%' && (r.pos == 0 || r.text[r.pos-1] == '\n') {
+			r.skipLine()
+			continue
+		}
+		if ch != ' ' && ch != '\t' && ch != '\r' && ch != '\n' {
+			return
+		}
+		r.pos += 1
+	}
+}
+
+func (r *pgnReader) skipLine() string {
+	start := r.pos
+	for r.pos < len(r.text) && r.text[r.pos] != '\n' {
+		r.pos += 1
+	}
+	return r.text[start:r.pos]
+}
+
+func (r *pgnReader) peek() byte {
+	if r.pos >= len(r.text) {
+		return 0
+	}
+	return r.text[r.pos]
+}
+
+func (r *pgnReader) readGame() (*PGNGame, error) {
+	pgnGame := &PGNGame{Tags: map[string]string{}, Result: "*"}
+	for r.skipSpace(); r.peek() == '['; r.skipSpace() {
+		name, value, err := r.readTag()
+		if err != nil {
+			return nil, err
+		}
+		pgnGame.Tags[name] = value
+	}
+	moves, comment, result, err := r.readLine(0)
+	if err != nil {
+		return nil, err
+	}
+	pgnGame.Moves = moves
+	pgnGame.Comment = comment
+	if result != "" {
+		pgnGame.Result = result
+	} else if tagResult, ok := pgnGame.Tags["Result"]; ok {
+		pgnGame.Result = tagResult
+	}
+	return pgnGame, nil
+}
+
+// readTag reads a tag pair like [Event "F/S Return Match"]
+func (r *pgnReader) readTag() (string, string, error) {
+	r.pos += 1 // '['
+	r.skipSpace()
+	start := r.pos
+	for r.pos < len(r.text) && isSymbolChar(r.text[r.pos]) {
+		r.pos += 1
+	}
+	name := r.text[start:r.pos]
+	r.skipSpace()
+	if name == "" || r.peek() != '"' {
+		return "", "", pgnError("malformed tag near '%s'", r.skipLine())
+	}
+	r.pos += 1
+	var value strings.Builder
+	for r.pos < len(r.text) && r.text[r.pos] != '"' {
+		if r.text[r.pos] == '\\' && r.pos+1 < len(r.text) {
+			r.pos += 1
+		}
+		value.WriteByte(r.text[r.pos])
+		r.pos += 1
+	}
+	if r.pos >= len(r.text) {
+		return "", "", pgnError("unterminated tag '%s'", name)
+	}
+	r.pos += 1 // '"'
+	r.skipSpace()
+	if r.peek() != ']' {
+		return "", "", pgnError("unterminated tag '%s'", name)
+	}
+	r.pos += 1
+	return name, value.String(), nil
+}
+
+// readLine reads moves until the end of a game or, for variations nested
+// at depth greater than 0, until the closing parenthesis. It returns the
+// moves, any comment preceding the first move and the game result.
+func (r *pgnReader) readLine(depth int) ([]PGNMove, string, string, error) {
+	moves := make([]PGNMove, 0)
+	comment := ""
+	for {
+		r.skipSpace()
+		if strings.HasPrefix(r.text[r.pos:], "e.p.") {
+			// Optional en passant marker
+			r.pos += 4
+			continue
+		}
+		ch := r.peek()
+		switch {
+		case ch == 0 || (ch == '[' && depth == 0):
+			if depth > 0 {
+				return nil, "", "", pgnError("unterminated variation")
+			}
+			// Game without result
+			return moves, comment, "", nil
+		case ch == '{' || ch == ';':
+			text, err := r.readComment()
+			if err != nil {
+				return nil, "", "", err
+			}
+			if len(moves) == 0 {
+				comment = joinComments(comment, text)
+			} else {
+				last := &moves[len(moves)-1]
+				last.Comment = joinComments(last.Comment, text)
+			}
+		case ch == '(':
+			if len(moves) == 0 {
+				return nil, "", "", pgnError("variation without a move")
+			}
+			r.pos += 1
+			variation, varComment, _, err := r.readLine(depth + 1)
+			if err != nil {
+				return nil, "", "", err
+			}
+			if len(variation) > 0 {
+				variation[0].Comment = joinComments(
+					varComment, variation[0].Comment)
+				last := &moves[len(moves)-1]
+				last.Variations = append(last.Variations, variation)
+			}
+		case ch == ')':
+			if depth == 0 {
+				return nil, "", "", pgnError("unexpected ')'")
+			}
+			r.pos += 1
+			return moves, comment, "", nil
+		case ch == '$' || ch == '!' || ch == '?':
+			nag, err := r.readNAG()
+			if err != nil {
+				return nil, "", "", err
+			}
+			if len(moves) == 0 {
+				return nil, "", "", pgnError("annotation without a move")
+			}
+			last := &moves[len(moves)-1]
+			last.NAGs = append(last.NAGs, nag)
+		case ch == '.':
+			r.pos += 1
+		case isSymbolChar(ch) || ch == '*':
+			token := r.readSymbol()
+			if pgnResults[token] {
+				if depth > 0 {
+					return nil, "", "", pgnError("result inside variation")
+				}
+				return moves, comment, token, nil
+			}
+			if _, err := strconv.Atoi(token); err == nil {
+				// Move number indication
+				continue
+			}
+			moves = append(moves, PGNMove{SAN: token})
+		default:
+			return nil, "", "", pgnError("unexpected character '%c'", ch)
+		}
+	}
+}
+
+// readComment reads a comment in braces or one running to the end of the
+// line after ';'
+func (r *pgnReader) readComment() (string, error) {
+	if r.text[r.pos] == ';' {
+		r.pos += 1
+		return strings.TrimSpace(r.skipLine()), nil
+	}
+	end := strings.IndexByte(r.text[r.pos:], '}')
+	if end == -1 {
+		return "", pgnError("unterminated comment")
+	}
+	text := r.text[r.pos+1 : r.pos+end]
+	r.pos += end + 1
+	return strings.Join(strings.Fields(text), " "), nil
+}
+
+// readNAG reads a glyph like $14 or a suffix annotation like !?
+func (r *pgnReader) readNAG() (int, error) {
+	start := r.pos
+	if r.text[r.pos] == '$' {
+		r.pos += 1
+		for r.pos < len(r.text) && r.text[r.pos] >= '0' &&
+			r.text[r.pos] <= '9' {
+			r.pos += 1
+		}
+		nag, err := strconv.Atoi(r.text[start+1 : r.pos])
+		if err != nil || nag > 255 {
+			return 0, pgnError("invalid annotation '%s'", r.text[start:r.pos])
+		}
+		return nag, nil
+	}
+	for r.pos < len(r.text) && (r.text[r.pos] == '!' || r.text[r.pos] == '?') {
+		r.pos += 1
+	}
+	nag, ok := suffixNAGs[r.text[start:r.pos]]
+	if !ok {
+		return 0, pgnError("invalid annotation '%s'", r.text[start:r.pos])
+	}
+	return nag, nil
+}
+
+func (r *pgnReader) readSymbol() string {
+	start := r.pos
+	for r.pos < len(r.text) &&
+		(isSymbolChar(r.text[r.pos]) || r.text[r.pos] == '*') {
+		r.pos += 1
+	}
+	return r.text[start:r.pos]
+}
+
+func isSymbolChar(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') ||
+		(ch >= '0' && ch <= '9') || strings.IndexByte("_+#=:-/", ch) != -1
+}
+
+func joinComments(first, second string) string {
+	if first == "" || second == "" {
+		return first + second
+	}
+	return first + " " + second
+}
+
+// startFEN returns the position the game starts from
+func (pg *PGNGame) startFEN() string {
+	if fen, ok := pg.Tags["FEN"]; ok {
+		return fen
+	}
+	return StartFEN
+}
+
+// replay validates all moves of the game and records the positions of the
+// main line.
+func (pg *PGNGame) replay() error {
+	g, err := NewGameFromFEN(pg.startFEN(), white)
+	if err != nil {
+		return err
+	}
+	pg.Positions = []string{g.FEN()}
+	return g.replayLine(pg.Moves, pg)
+}
+
+// replayLine plays given moves validating each of them. Positions are
+// recorded for the main line, while variations are taken back once done.
+func (g *Game) replayLine(moves []PGNMove, pg *PGNGame) error {
+	played := 0
+	for i := range moves {
+		mv := &moves[i]
+		for _, variation := range mv.Variations {
+			err := g.replayLine(variation, nil)
+			if err != nil {
+				return err
+			}
+		}
+		bm, err := g.fromSAN(mv.SAN)
+		if err != nil {
+			return err
+		}
+		mv.Move, _ = bm.toUserMove()
+		err = g.PlayMove(bm)
+		if err != nil {
+			return err
+		}
+		played += 1
+		if pg != nil {
+			pg.Positions = append(pg.Positions, g.FEN())
+		}
+	}
+	if pg != nil {
+		return nil
+	}
+	for ; played > 0; played-- {
+		g.UndoMove()
+	}
+	return nil
+}
+
+// LoadPly sets up a game at the position reached after given number of
+// half moves of the main line. Moves up to that point are part of the game
+// and can be undone or exported. As with NewGame, colorChoice is the color
+// played by the user.
+func (pg *PGNGame) LoadPly(ply, colorChoice int) (*Game, error) {
+	if ply < 0 || ply > len(pg.Moves) {
+		errMsg := fmt.Sprintf("Invalid ply %d. Game has %d half moves",
+			ply, len(pg.Moves))
+		return nil, errors.New(errMsg)
+	}
+	g, err := NewGameFromFEN(pg.startFEN(), colorChoice)
+	if err != nil {
+		return nil, err
+	}
+	for _, mv := range pg.Moves[:ply] {
+		bm, err := g.fromSAN(mv.SAN)
+		if err != nil {
+			return nil, err
+		}
+		err = g.PlayMove(bm)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
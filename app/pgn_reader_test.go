@@ -0,0 +1,100 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePGNVariations(t *testing.T) {
+	pgn := `[Event "Test"]
+[Result "1-0"]
+
+1. e4 {Best by test} e5 (1... c5 2. Nf3 (2. c3 d5) 2... d6) 2. Nf3 $1 Nc6
+3. Bb5 a6!? 1-0
+`
+	games, err := ParsePGN(strings.NewReader(pgn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 {
+		t.Fatalf("got %d games, want 1", len(games))
+	}
+	game := games[0]
+	if game.Tags["Event"] != "Test" || game.Result != "1-0" {
+		t.Errorf("got tags %v and result %s", game.Tags, game.Result)
+	}
+	sans := func(moves []PGNMove) []string {
+		list := make([]string, 0, len(moves))
+		for _, mv := range moves {
+			list = append(list, mv.SAN)
+		}
+		return list
+	}
+	want := []string{"e4", "e5", "Nf3", "Nc6", "Bb5", "a6"}
+	if got := sans(game.Moves); !reflect.DeepEqual(got, want) {
+		t.Errorf("main line %v, want %v", got, want)
+	}
+	if len(game.Positions) != len(want)+1 {
+		t.Errorf("got %d positions, want %d", len(game.Positions),
+			len(want)+1)
+	}
+	if game.Moves[0].Comment != "Best by test" {
+		t.Errorf("comment %q", game.Moves[0].Comment)
+	}
+	if game.Moves[0].Move.From != "e2" || game.Moves[0].Move.To != "e4" {
+		t.Errorf("first move %v", game.Moves[0].Move)
+	}
+	if !reflect.DeepEqual(game.Moves[2].NAGs, []int{1}) ||
+		!reflect.DeepEqual(game.Moves[5].NAGs, []int{5}) {
+		t.Errorf("annotations %v and %v", game.Moves[2].NAGs,
+			game.Moves[5].NAGs)
+	}
+	variations := game.Moves[1].Variations
+	if len(variations) != 1 {
+		t.Fatalf("got %d variations, want 1", len(variations))
+	}
+	want = []string{"c5", "Nf3", "d6"}
+	if got := sans(variations[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("variation %v, want %v", got, want)
+	}
+	nested := variations[0][1].Variations
+	want = []string{"c3", "d5"}
+	if len(nested) != 1 || !reflect.DeepEqual(sans(nested[0]), want) {
+		t.Errorf("nested variations %v, want [%v]", nested, want)
+	}
+}
+
+func TestParsePGNErrors(t *testing.T) {
+	tests := []struct {
+		pgn    string
+		reason string
+	}{
+		{"1. e4 {oops", "unterminated comment"},
+		{"[Event \"Test", "unterminated tag"},
+		{"[Event \"Test\"\n1. e4", "unterminated tag"},
+		{"[Event]\n1. e4", "malformed tag"},
+		{"1. e4 e5 ) 2. Nf3", "unexpected ')'"},
+		{"1. e4 (1. d4 d5", "unterminated variation"},
+		{"( 1. e4", "variation without a move"},
+		{"1. e4 $ e5", "invalid annotation"},
+		{"1. e4 $256", "invalid annotation"},
+		{"1. e4 ?!? e5", "invalid annotation"},
+		{"$1 1. e4", "annotation without a move"},
+		{"1. e4 (1. d4 1-0) e5", "result inside variation"},
+		{"1. e4 & e5", "unexpected character"},
+		{"1. e5", "Game 1"},
+		{"1. e4 e5 (1... Ke7) 2. Nf3", "Game 1"},
+	}
+	for _, test := range tests {
+		_, err := ParsePGN(strings.NewReader(test.pgn))
+		if err == nil {
+			t.Errorf("ParsePGN(%q) succeeded", test.pgn)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("ParsePGN(%q) = %v, want error about %q", test.pgn,
+				err, test.reason)
+		}
+	}
+}
@@ -4,8 +4,8 @@ type piece struct {
 	id            int
 	name          string
 	color         int
-	position      uint // position in powers of 2
-	moveGenerator func(*piece) ([]boardMove, uint)
+	square        int // board index of the square the piece is on
+	moveGenerator func(*Game, *piece) ([]boardMove, uint64)
 	captured      bool
 	moveCount     int // Number of times piece has moved
 	enpassantMove int // First move of pawn
@@ -14,7 +14,7 @@ type piece struct {
 
 type pieceMeta struct {
 	name          string
-	moveGenerator func(*piece) ([]boardMove, uint)
+	moveGenerator func(*Game, *piece) ([]boardMove, uint64)
 }
 
 var weights = map[int]int{
@@ -26,30 +26,39 @@ var weights = map[int]int{
 	pawn:   1,
 }
 
+var pieceLetters = map[int]string{
+	king:   "K",
+	queen:  "Q",
+	rook:   "R",
+	bishop: "B",
+	knight: "N",
+	pawn:   "P",
+}
+
 var blackMeta = map[int]pieceMeta{
-	king:   pieceMeta{"Black King", kingMoves},
-	queen:  pieceMeta{"Black Queen", queenMoves},
-	rook:   pieceMeta{"Black Rook", rookMoves},
-	bishop: pieceMeta{"Black Bishop", bishopMoves},
-	knight: pieceMeta{"Black Knight", knightMoves},
-	pawn:   pieceMeta{"Black Pawn", blackPawnMoves},
+	king:   pieceMeta{"Black King", (*Game).kingMoves},
+	queen:  pieceMeta{"Black Queen", (*Game).queenMoves},
+	rook:   pieceMeta{"Black Rook", (*Game).rookMoves},
+	bishop: pieceMeta{"Black Bishop", (*Game).bishopMoves},
+	knight: pieceMeta{"Black Knight", (*Game).knightMoves},
+	pawn:   pieceMeta{"Black Pawn", (*Game).pawnMoves},
 }
 
 var whiteMeta = map[int]pieceMeta{
-	king:   pieceMeta{"White King", kingMoves},
-	queen:  pieceMeta{"White Queen", queenMoves},
-	rook:   pieceMeta{"White Rook", rookMoves},
-	bishop: pieceMeta{"White Bishop", bishopMoves},
-	knight: pieceMeta{"White Knight", knightMoves},
-	pawn:   pieceMeta{"White Pawn", whitePawnMoves},
+	king:   pieceMeta{"White King", (*Game).kingMoves},
+	queen:  pieceMeta{"White Queen", (*Game).queenMoves},
+	rook:   pieceMeta{"White Rook", (*Game).rookMoves},
+	bishop: pieceMeta{"White Bishop", (*Game).bishopMoves},
+	knight: pieceMeta{"White Knight", (*Game).knightMoves},
+	pawn:   pieceMeta{"White Pawn", (*Game).pawnMoves},
 }
 
-func newBlackPiece(pieceType int, position uint) *piece {
+func newBlackPiece(pieceType, square int) *piece {
 	return &piece{
 		id:            pieceType,
 		name:          blackMeta[pieceType].name,
 		color:         black,
-		position:      position,
+		square:        square,
 		moveGenerator: blackMeta[pieceType].moveGenerator,
 		captured:      false,
 		moveCount:     0,
@@ -57,12 +66,12 @@ func newBlackPiece(pieceType int, position uint) *piece {
 	}
 }
 
-func newWhitePiece(pieceType int, position uint) *piece {
+func newWhitePiece(pieceType, square int) *piece {
 	return &piece{
 		id:            pieceType,
 		name:          whiteMeta[pieceType].name,
 		color:         white,
-		position:      position,
+		square:        square,
 		moveGenerator: whiteMeta[pieceType].moveGenerator,
 		captured:      false,
 		moveCount:     0,
@@ -70,3 +79,10 @@ func newWhitePiece(pieceType int, position uint) *piece {
 		promotedBy:    nil,
 	}
 }
+
+func otherColor(color int) int {
+	if color == white {
+		return black
+	}
+	return white
+}
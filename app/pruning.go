@@ -0,0 +1,93 @@
+package app
+
+// Selective search: moves and positions unlikely to affect the result are
+// searched less deeply than others or not at all. This lets the search go
+// deeper along the lines that matter in the same time.
+
+// Remaining depth up to which futility pruning is done
+const futilityDepth = 3
+
+// futilityMargin is the most a quiet move is assumed to raise the static
+// evaluation by, per ply of remaining depth
+const futilityMargin = 2
+
+// Remaining depth from which null-move pruning is done
+const nullMoveDepth = 3
+
+// Remaining depth and number of moves searched from which moves are reduced
+const (
+	reductionDepth = 3
+	reductionMoves = 3
+)
+
+// reverseFutility tells whether the static evaluation of the position is so
+// far above beta that no move is likely to bring it back, along with the
+// score to return in that case
+func (g *Game) reverseFutility(
+	beta float32, depth int, staticEval float32) (float32, bool) {
+	margin := float32(futilityMargin * depth)
+	if staticEval-margin >= beta {
+		return staticEval - margin, true
+	}
+	return 0, false
+}
+
+// nullMove lets the side to move pass and searches the position to a
+// reduced depth. If passing still scores at least beta, making a move
+// surely does, so the position need not be searched. It tells whether that
+// is the case, along with the score to return.
+func (g *Game) nullMove(beta float32, ply, depth int) (float32, bool) {
+	if !g.pruning.NullMove || depth < nullMoveDepth {
+		return 0, false
+	}
+	if ply == 0 || g.searchPath[ply-1] == (boardMove{}) {
+		// Passing twice in a row proves nothing
+		return 0, false
+	}
+	// In pawn endings passing may well be better than any move
+	// (zugzwang), so the null move would wrongly prune the position
+	brd := g.board
+	if brd.colors[g.turn]&^(brd.types[pawn]|brd.types[king]) == 0 {
+		return 0, false
+	}
+	reduction := 2
+	if depth > 6 {
+		reduction = 3
+	}
+	myTurn := g.MyTurn()
+	g.makeNullMove()
+	g.searchPath[ply] = boardMove{}
+	opponentMoves, _ := g.generateMoves(!myTurn)
+	_, score := g.search(-beta, -beta+nullWindow, ply+1,
+		depth-1-reduction, opponentMoves)
+	score = -score
+	g.undoNullMove()
+	if g.aborted || score < beta {
+		return 0, false
+	}
+	// A mate found after passing may not exist after a move, so only the
+	// bound is returned then
+	if score >= mateScore-maxPly {
+		score = beta
+	}
+	return score, true
+}
+
+// reduction returns the number of plies to reduce the search of a move
+// by, given the number of moves searched before it. Moves late in the
+// ordering rarely turn out best, so they are searched less deeply unless
+// they prove better than the moves before them.
+func (g *Game) reduction(
+	ply, depth, searched int, inCheck bool, move boardMove) int {
+	if !g.pruning.Reductions || inCheck || depth < reductionDepth ||
+		searched < reductionMoves {
+		return 0
+	}
+	if move == g.killers[ply][0] || move == g.killers[ply][1] {
+		return 0
+	}
+	if depth >= 6 && searched >= 2*reductionMoves {
+		return 2
+	}
+	return 1
+}
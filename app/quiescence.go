@@ -0,0 +1,131 @@
+package app
+
+import (
+	"sort"
+)
+
+// deltaMargin is added to the material won by a capture when deciding
+// whether it may raise the score enough to be worth searching, to allow for
+// positional gains
+const deltaMargin = 2
+
+// quiesce searches captures and promotions from a leaf of the main search
+// until the position is quiet, so that positions are not evaluated in the
+// middle of an exchange. The side to move may stand pat, settling for the
+// static evaluation when no capture improves on it. Scores are from the
+// side to move's perspective and bounded by alpha and beta as in search.
+func (g *Game) quiesce(alpha, beta float32, ply int) float32 {
+	if g.outOfLimits() {
+		return 0
+	}
+	if ply >= maxPly {
+		return g.evaluate()
+	}
+	if g.inCheck(g.turn) {
+		return g.evade(alpha, beta, ply)
+	}
+	standPat := g.evaluate()
+	if standPat >= beta {
+		return standPat
+	}
+	if standPat > alpha {
+		alpha = standPat
+	}
+	bestScore := standPat
+	for _, move := range g.noisyMoves(g.MyTurn()) {
+		// Delta pruning: skip captures which cannot raise the score even if
+		// the opponent fails to recapture
+		if standPat+float32(materialGain(move)+deltaMargin) <= alpha {
+			continue
+		}
+		score, ok := g.quiesceMove(alpha, beta, ply, move)
+		if g.aborted {
+			return 0
+		}
+		if !ok || score <= bestScore {
+			continue
+		}
+		bestScore = score
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	return bestScore
+}
+
+// evade searches all moves of a side in check, as standing pat is not an
+// option when the king is attacked. Escaping the check may not be possible.
+func (g *Game) evade(alpha, beta float32, ply int) float32 {
+	moves, _ := g.generateMoves(g.MyTurn())
+	bestScore := -mateScore + float32(ply)
+	for _, move := range moves {
+		score, ok := g.quiesceMove(alpha, beta, ply, move)
+		if g.aborted {
+			return 0
+		}
+		if !ok || score <= bestScore {
+			continue
+		}
+		bestScore = score
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	return bestScore
+}
+
+// quiesceMove makes given move and searches the captures following it. It
+// returns false if the move is not legal.
+func (g *Game) quiesceMove(
+	alpha, beta float32, ply int, move boardMove) (float32, bool) {
+	g.nodes += 1
+	g.qnodes += 1
+	if !g.isMoveValid(move) {
+		return 0, false
+	}
+	myTurn := g.MyTurn()
+	g.alterPosition(move)
+	_, attacks := g.generateMoves(!myTurn)
+	if g.inCheckSimple(myTurn, attacks) {
+		g.undoMove(move)
+		return 0, false
+	}
+	score := -g.quiesce(-beta, -alpha, ply+1)
+	g.undoMove(move)
+	return score, true
+}
+
+// noisyMoves returns the captures and promotions of the side to move in
+// MVV-LVA order. Moves losing material by static exchange evaluation are
+// left out, as they would rarely raise the score.
+func (g *Game) noisyMoves(myTurn bool) []boardMove {
+	moves, _ := g.generateMoves(myTurn)
+	noisy := moves[:0]
+	scores := make([]int, 0, len(moves))
+	for _, move := range moves {
+		if isNoisy(move) && !g.losing(move) {
+			noisy = append(noisy, move)
+			scores = append(scores, g.mvvLva(move))
+		}
+	}
+	sort.Stable(orderedMoves{noisy, scores})
+	return noisy
+}
+
+// materialGain returns the material won by a move
+func materialGain(move boardMove) int {
+	gain := 0
+	if move.captured != nil {
+		gain += weights[move.captured.id]
+	}
+	if move.PromotedPc > 0 {
+		gain += weights[move.PromotedPc] - weights[pawn]
+	}
+	return gain
+}
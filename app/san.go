@@ -0,0 +1,207 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sides returns pieces of given color followed by pieces of its opponent
+func (g *Game) sides(color int) (map[int][]*piece, map[int][]*piece) {
+	if color == g.myColor {
+		return g.myPieces, g.otherPieces
+	}
+	return g.otherPieces, g.myPieces
+}
+
+// legalMovesOf lists all legal moves of pieces of given type and color
+func (g *Game) legalMovesOf(color, pieceType int) []boardMove {
+	own, _ := g.sides(color)
+	legal := make([]boardMove, 0)
+	for _, pc := range own[pieceType] {
+		if pc.captured {
+			continue
+		}
+		moves, _ := pc.moveGenerator(g, pc)
+		for _, move := range moves {
+			if g.isMoveLegal(move) {
+				legal = append(legal, move)
+			}
+		}
+	}
+	return legal
+}
+
+// toSAN converts a legal move to Standard Algebraic Notation like Nbd7,
+// exd5, O-O or e8=Q+. It must be called before the move is played.
+func (g *Game) toSAN(bm boardMove) string {
+	pc := g.board.pieces[bm.From]
+	from, _ := toCoordinates(bm.From)
+	to, _ := toCoordinates(bm.To)
+	var san string
+	switch {
+	case bm.castlingFrom != -1 && bm.To > bm.From:
+		san = "O-O"
+	case bm.castlingFrom != -1:
+		san = "O-O-O"
+	case pc.id == pawn:
+		if from[0] != to[0] {
+			// Pawns always capture diagonally, en passant included
+			san = from[:1] + "x"
+		}
+		san += to
+		if bm.PromotedPc > 0 {
+			san += "=" + pieceLetters[bm.PromotedPc]
+		}
+	default:
+		san = pieceLetters[pc.id] + g.disambiguation(bm, pc)
+		if bm.captured != nil {
+			san += "x"
+		}
+		san += to
+	}
+	return san + g.checkSuffix(bm, pc.color)
+}
+
+// disambiguation returns the file, rank or square of departure needed to
+// tell a move apart from moves of other pieces of the same type.
+func (g *Game) disambiguation(bm boardMove, pc *piece) string {
+	from, _ := toCoordinates(bm.From)
+	ambiguous, sameFile, sameRank := false, false, false
+	for _, move := range g.legalMovesOf(pc.color, pc.id) {
+		if move.To != bm.To || move.From == bm.From {
+			continue
+		}
+		ambiguous = true
+		other, _ := toCoordinates(move.From)
+		if other[0] == from[0] {
+			sameFile = true
+		}
+		if other[1] == from[1] {
+			sameRank = true
+		}
+	}
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return from[:1]
+	case !sameRank:
+		return from[1:]
+	}
+	return from
+}
+
+// checkSuffix returns '#' if move checkmates, '+' if it checks the opponent
+// and an empty string otherwise.
+func (g *Game) checkSuffix(bm boardMove, color int) string {
+	g.alterPosition(bm)
+	defer g.undoMove(bm)
+	if !g.inCheck(otherColor(color)) {
+		return ""
+	}
+	if g.legalMoves(color != g.myColor) == 0 {
+		return "#"
+	}
+	return "+"
+}
+
+// sanPieces maps piece letters used in SAN to piece types
+var sanPieces = map[byte]int{
+	'K': king,
+	'Q': queen,
+	'R': rook,
+	'B': bishop,
+	'N': knight,
+}
+
+// ParseSAN converts a move of the side to move written in Standard
+// Algebraic Notation, like Nf3, exd5, O-O or e8=Q+, to a board move.
+func (g *Game) ParseSAN(san string) (boardMove, error) {
+	return g.fromSAN(san)
+}
+
+// ToSAN converts a legal move of the side to move to Standard Algebraic
+// Notation, including check and checkmate suffixes.
+func (g *Game) ToSAN(mv boardMove) (string, error) {
+	pc := g.board.pieces[mv.From]
+	if pc == nil || pc.color != g.turn {
+		return "", errors.New("Invalid move")
+	}
+	for _, move := range g.legalMovesOf(pc.color, pc.id) {
+		if move == mv {
+			return g.toSAN(mv), nil
+		}
+	}
+	return "", errors.New("Illegal move")
+}
+
+// fromSAN finds the legal move of the side to move matching a move in
+// Standard Algebraic Notation. Over-disambiguated moves like Ng1f3 and
+// castling written with zeros are accepted as well.
+func (g *Game) fromSAN(san string) (boardMove, error) {
+	s := strings.TrimRight(san, "+#!?")
+	s = strings.Replace(s, "0", "O", -1)
+	if s == "O-O" || s == "O-O-O" {
+		for _, move := range g.legalMovesOf(g.turn, king) {
+			if move.castlingFrom == -1 {
+				continue
+			}
+			if (s == "O-O") == (move.To > move.From) {
+				return move, nil
+			}
+		}
+		return boardMove{}, sanError(san, "Illegal move")
+	}
+	pieceType := pawn
+	if len(s) > 0 && sanPieces[s[0]] != 0 {
+		pieceType = sanPieces[s[0]]
+		s = s[1:]
+	}
+	promotedPc := -1
+	if i := strings.IndexByte(s, '='); i != -1 {
+		s = s[:i] + s[i+1:]
+	}
+	if len(s) > 2 && sanPieces[s[len(s)-1]] != 0 {
+		promotedPc = sanPieces[s[len(s)-1]]
+		s = s[:len(s)-1]
+	}
+	// Capture and long algebraic markers carry no information
+	s = strings.NewReplacer("x", "", ":", "", "-", "").Replace(s)
+	if len(s) < 2 || len(s) > 4 {
+		return boardMove{}, sanError(san, "Unrecognised move")
+	}
+	to, err := toIndex(s[len(s)-2:])
+	if err != nil {
+		return boardMove{}, sanError(san, err.Error())
+	}
+	hint := s[:len(s)-2]
+	matches := make([]boardMove, 0, 1)
+	for _, move := range g.legalMovesOf(g.turn, pieceType) {
+		if move.To != to || move.PromotedPc != promotedPc {
+			continue
+		}
+		from, _ := toCoordinates(move.From)
+		matched := true
+		for i := 0; i < len(hint); i++ {
+			if hint[i] != from[0] && hint[i] != from[1] {
+				matched = false
+			}
+		}
+		if matched {
+			matches = append(matches, move)
+		}
+	}
+	if len(matches) == 0 {
+		return boardMove{}, sanError(san, "Illegal move")
+	}
+	if len(matches) > 1 {
+		return boardMove{}, sanError(san, "Ambiguous move")
+	}
+	return matches[0], nil
+}
+
+func sanError(san, reason string) error {
+	errMsg := fmt.Sprintf("%s: '%s'", reason, san)
+	return errors.New(errMsg)
+}
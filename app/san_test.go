@@ -0,0 +1,79 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestParseSAN(t *testing.T) {
+	tests := []struct {
+		fen  string
+		san  string
+		move string // Long algebraic notation
+	}{
+		{StartFEN, "e4", "e2e4"},
+		{StartFEN, "Nf3", "g1f3"},
+		{StartFEN, "Ng1f3", "g1f3"},
+		{StartFEN, "Ng1-f3", "g1f3"},
+		{"1n2k3/8/5n2/8/8/8/8/4K3 b - - 0 1", "Nbd7", "b8d7"},
+		{"1n2k3/8/5n2/8/8/8/8/4K3 b - - 0 1", "Nfd7", "f6d7"},
+		{"1n2k3/8/5n2/8/8/8/8/4K3 b - - 0 1", "N8d7", "b8d7"},
+		{"k7/8/8/8/8/4R3/8/4R1K1 w - - 0 1", "R1e2", "e1e2"},
+		{"k7/8/8/8/8/4R3/8/4R1K1 w - - 0 1", "R3e2", "e3e2"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "O-O", "e1g1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "0-0", "e1g1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "0-0-0", "e1c1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "O-O-O", "e8c8"},
+		{"8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e8=Q", "e7e8q"},
+		{"8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e8Q+", "e7e8q"},
+		{"8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e8=N", "e7e8n"},
+		{"3r4/4P3/8/8/8/8/k7/4K3 w - - 0 1", "exd8=R", "e7d8r"},
+	}
+	for _, test := range tests {
+		g, err := NewGameFromFEN(test.fen, black)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bm, err := g.ParseSAN(test.san)
+		if err != nil {
+			t.Errorf("ParseSAN(%q) failed: %v", test.san, err)
+			continue
+		}
+		uMove, _ := bm.toUserMove()
+		if got := uMove.LongAlgebraic(); got != test.move {
+			t.Errorf("ParseSAN(%q) = %s, want %s", test.san, got, test.move)
+		}
+	}
+}
+
+func TestParseSANErrors(t *testing.T) {
+	tests := []struct {
+		fen string
+		san string
+	}{
+		{StartFEN, ""},
+		{StartFEN, "e"},
+		{StartFEN, "e2é"},
+		{StartFEN, "é"},
+		{StartFEN, "Nxé3"},
+		{StartFEN, "Zf3"},
+		{StartFEN, "e9"},
+		{StartFEN, "i3"},
+		{StartFEN, "e5"},
+		{StartFEN, "O-O"},
+		{StartFEN, "O-O-O-O"},
+		{StartFEN, "Nb1c2d3"},
+		{"1n2k3/8/5n2/8/8/8/8/4K3 b - - 0 1", "Nd7"},
+		{"k7/8/8/8/8/4R3/8/4R1K1 w - - 0 1", "Re2"},
+		{"8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e8"},
+		{"8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e8=K"},
+	}
+	for _, test := range tests {
+		g, err := NewGameFromFEN(test.fen, black)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := g.ParseSAN(test.san); err == nil {
+			t.Errorf("ParseSAN(%q) succeeded in %s", test.san, test.fen)
+		}
+	}
+}
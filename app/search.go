@@ -1,8 +1,12 @@
 package app
 
 import (
+	"context"
 	"math"
 	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type sortInt []boardMove
@@ -22,89 +26,439 @@ func (bm sortInt) Swap(i, j int) {
 	bm[i], bm[j] = bm[j], bm[i]
 }
 
-func search(
-	myTurn bool, bestParentScore float32, depth int,
-	moves []boardMove) (boardMove, float32) {
-	if depth > maxDepth {
-		return boardMove{}, eval()
+// infinity is beyond any score
+const infinity float32 = math.MaxInt32
+
+// nullWindow is the width of the window of searches which only tell
+// whether a move is better than a given score
+const nullWindow float32 = 0.01
+
+// Iterations from which the search window is narrowed around the score of
+// the previous iteration, and the initial half width of that window
+const (
+	aspirationDepth          = 4
+	aspirationWindow float32 = 0.5
+)
+
+// maxAspirationWindow is the widest window searched before searching
+// without bounds
+const maxAspirationWindow float32 = 4
+
+// think searches for the engine's move with the configured number of
+// threads (Lazy SMP). Helper threads search copies of the game alongside
+// the main thread and share the transposition table with it, so that the
+// main thread finds positions they searched in the table. The move and
+// depth are those of the main thread, whose search stops the helpers, while
+// node counts add up all threads.
+func (g *Game) think(
+	ctx context.Context, limits SearchLimits) (boardMove, SearchInfo) {
+	g.counts = nil
+	if g.threads <= 1 {
+		return g.deepen(ctx, limits)
+	}
+	g.counts = make([]nodeCount, g.threads)
+	helperCtx, stopHelpers := context.WithCancel(ctx)
+	var helpers sync.WaitGroup
+	for thread := 1; thread < g.threads; thread++ {
+		helper := g.helper(thread)
+		helper.counts = g.counts
+		helpers.Add(1)
+		go func() {
+			defer helpers.Done()
+			helper.deepen(helperCtx, limits)
+		}()
+	}
+	move, info := g.deepen(ctx, limits)
+	stopHelpers()
+	helpers.Wait()
+	return move, info
+}
+
+// helper returns a copy of the game to be searched by given helper thread
+func (g *Game) helper(thread int) *Game {
+	h := g.Copy()
+	h.tt = g.tt
+	h.pruning = g.pruning
+	h.thread = thread
+	return h
+}
+
+// nodeCount is the number of nodes a thread has searched so far
+type nodeCount struct {
+	nodes  int64
+	qnodes int64
+}
+
+// publishNodes makes the nodes searched by this thread known to the other
+// threads of the search
+func (g *Game) publishNodes() {
+	if g.counts == nil {
+		return
+	}
+	count := &g.counts[g.thread]
+	atomic.StoreInt64(&count.nodes, int64(g.nodes))
+	atomic.StoreInt64(&count.qnodes, int64(g.qnodes))
+}
+
+// searchedNodes returns the nodes searched by all threads, and the part of
+// them in quiescence search. Counts of helper threads lag behind by up to
+// the nodes searched between looks at the clock.
+func (g *Game) searchedNodes() (int, int) {
+	if g.counts == nil {
+		return g.nodes, g.qnodes
 	}
+	g.publishNodes()
+	nodes, qnodes := 0, 0
+	for i := range g.counts {
+		nodes += int(atomic.LoadInt64(&g.counts[i].nodes))
+		qnodes += int(atomic.LoadInt64(&g.counts[i].qnodes))
+	}
+	return nodes, qnodes
+}
+
+// deepen searches to increasing depths up to the maximum depth. Each
+// iteration starts with the principal variation of the previous one, which
+// is found faster thanks to it. The search stops early once it runs out of
+// given limits or the context is done, and the result of the last completed
+// iteration is returned.
+func (g *Game) deepen(
+	ctx context.Context, limits SearchLimits) (boardMove, SearchInfo) {
+	g.searchStart = time.Now()
+	g.stop = ctx.Done()
+	g.limits = limits
+	g.optimumTime, g.maxTime = limits.thinkingTime(g.myColor)
+	g.nextClockCheck = clockCheckInterval
+	g.aborted = false
+	g.nodes = 0
+	g.qnodes = 0
+	maxDepth := limits.depth(g.maxDepth)
+	g.pv = make([][]boardMove, maxPly+1)
+	g.searchPath = make([]boardMove, maxPly+1)
+	g.killers = make([][2]boardMove, maxPly+1)
+	g.counterMoves = &[64][64]boardMove{}
+	g.historyScores = &[3][64][64]int{}
+	g.prevPV = nil
 	var bestMove boardMove
-	board := game.board
-	if depth == 1 {
-		moves, _ = generateMoves(myTurn)
+	var info SearchInfo
+	// Every other helper thread searches a ply deeper than the main
+	// thread, so that threads do not all search the same positions
+	for depth := 1 + g.thread%2; depth <= maxDepth; depth++ {
+		g.searchDepth = depth
+		move, score := g.aspirate(depth, info.Score)
+		if g.aborted {
+			break
+		}
+		bestMove = move
+		g.prevPV = append([]boardMove{}, g.pv[0]...)
+		pv := make([]UserMove, 0, len(g.prevPV))
+		for _, pvMove := range g.prevPV {
+			uMove, _ := pvMove.toUserMove()
+			pv = append(pv, uMove)
+		}
+		elapsed := time.Since(g.searchStart)
+		nodes, qnodes := g.searchedNodes()
+		info = SearchInfo{
+			Depth:    depth,
+			Nodes:    nodes,
+			QNodes:   qnodes,
+			Score:    score,
+			Mate:     mateIn(score),
+			Duration: elapsed,
+			PV:       pv,
+		}
+		if g.onIteration != nil {
+			g.onIteration(info)
+		}
+		// The next iteration takes longer than all earlier ones together,
+		// so it is not started once half the optimum time is used up
+		if g.optimumTime > 0 && elapsed >= g.optimumTime/2 {
+			break
+		}
+		if limits.Nodes > 0 && g.nodes >= limits.Nodes {
+			break
+		}
 	}
-	if myTurn {
-		maxScore := float32(math.MinInt32)
-		for _, move := range moves {
-			evaluationsPerSearch += 1
-			if !isMoveValid(move) {
-				continue
-			}
-			board.alterPosition(move)
-			opponentMoves, attacks := generateMoves(!myTurn)
-			if inCheckSimple(myTurn, attacks) {
-				board.undoMove(move)
-				continue
-			}
-			_, score := search(!myTurn, maxScore, depth+1, opponentMoves)
-			board.undoMove(move)
-			if score < maxScore {
-				continue
-			}
-			maxScore = score
-			bestMove = move
-			if maxScore >= bestParentScore {
-				return bestMove, maxScore // alpha-pruning
-			}
+	return bestMove, info
+}
+
+// aspirate searches the root to given depth within a narrow window around
+// the score of the previous iteration, as the score rarely changes much.
+// Searching with a narrow window cuts off more. When the score falls
+// outside the window, the search is repeated with the window widened on
+// that side.
+func (g *Game) aspirate(depth int, prevScore float32) (boardMove, float32) {
+	alpha, beta := -infinity, infinity
+	window := aspirationWindow
+	if depth >= aspirationDepth {
+		alpha, beta = prevScore-window, prevScore+window
+	}
+	for {
+		g.followPV = true
+		move, score := g.search(alpha, beta, 0, depth, nil)
+		switch {
+		case g.aborted:
+			return move, score
+		case score <= alpha:
+			alpha = score - window
+		case score >= beta:
+			beta = score + window
+		default:
+			return move, score
+		}
+		window *= 2
+		if window > maxAspirationWindow {
+			alpha, beta = -infinity, infinity
 		}
-		return bestMove, maxScore
 	}
-	minScore := float32(math.MaxInt32)
+}
+
+// search returns the best move of the side to move and its score from the
+// side to move's perspective, searching given number of plies. ply is the
+// number of moves made from the root. Scores are exact between alpha and
+// beta. A score at most alpha means the move is no better than one found
+// earlier, and a score at least beta means the opponent will avoid the
+// position, so searching is stopped early. Such scores are bounds only.
+func (g *Game) search(
+	alpha, beta float32, ply, depth int,
+	moves []boardMove) (boardMove, float32) {
+	g.pv[ply] = g.pv[ply][:0]
+	if ply > 0 && g.isDraw() {
+		return boardMove{}, 0
+	}
+	if g.outOfLimits() {
+		return boardMove{}, 0
+	}
+	if ply >= maxPly {
+		return boardMove{}, g.evaluate()
+	}
+	// Nodes searched with a null window only tell whether the score
+	// passes alpha, so their moves cannot be on the principal variation
+	pvNode := beta-alpha > 2*nullWindow
+	// Mate distance pruning: no line scores better than mating right away
+	// or worse than being mated right away
+	if ply > 0 {
+		if mated := -mateScore + float32(ply); alpha < mated {
+			alpha = mated
+		}
+		if mating := mateScore - float32(ply+1); beta > mating {
+			beta = mating
+		}
+		if alpha >= beta {
+			return boardMove{}, alpha
+		}
+	}
+	// Check extension: positions in check are searched a ply deeper, so
+	// that the search does not end in the middle of escaping a check
+	inCheck := g.inCheck(g.turn)
+	if inCheck {
+		depth += 1
+	}
+	if depth <= 0 {
+		return boardMove{}, g.quiesce(alpha, beta, ply)
+	}
+	myTurn := g.MyTurn()
+	if ply == 0 {
+		moves, _ = g.generateMoves(myTurn)
+	}
+	key := g.board.key
+	entry, hashHit := g.tt.probe(key)
+	if hashHit && !pvNode && int(entry.depth) >= depth {
+		score := scoreFromTT(entry.score, ply)
+		switch {
+		case entry.bound == exactBound,
+			entry.bound == lowerBound && score >= beta,
+			entry.bound == upperBound && score <= alpha:
+			return boardMove{}, score
+		}
+	}
+	// Futility pruning is only done near the leaves away from the
+	// principal variation, and not when mating, as static evaluation tells
+	// nothing about how fast a mate is
+	futility := !pvNode && !inCheck && g.pruning.Futility &&
+		depth <= futilityDepth && !isMateScore(alpha) && !isMateScore(beta)
+	var staticEval float32
+	if futility {
+		staticEval = g.evaluate()
+		if score, ok := g.reverseFutility(beta, depth, staticEval); ok {
+			return boardMove{}, score
+		}
+	}
+	if !pvNode && !inCheck {
+		if score, ok := g.nullMove(beta, ply, depth); ok {
+			return boardMove{}, score
+		}
+	}
+	g.orderMoves(moves, ply)
+	if hashHit {
+		hashMoveFirst(moves, entry)
+	}
+	g.pvMoveFirst(moves, ply)
+	// Quiet moves are futile if even a gain of the futility margin would
+	// not raise the score above alpha
+	margin := float32(futilityMargin * depth)
+	var bestMove boardMove
+	bestScore := -infinity
+	bound := upperBound
+	legal := 0
 	for _, move := range moves {
-		evaluationsPerSearch += 1
-		if !isMoveValid(move) {
+		futile := futility && staticEval+margin <= alpha
+		reduction := g.reduction(ply, depth, legal, inCheck, move)
+		score, result := g.searchMove(
+			alpha, beta, ply, depth, move, legal == 0, futile, reduction)
+		if g.aborted {
+			return boardMove{}, 0
+		}
+		if result == illegalMove {
 			continue
 		}
-		board.alterPosition(move)
-		opponentMoves, attacks := generateMoves(!myTurn)
-		if inCheckSimple(myTurn, attacks) {
-			board.undoMove(move)
+		legal += 1
+		if result == prunedMove {
+			score = staticEval + margin
+		}
+		if score <= bestScore {
 			continue
 		}
-		_, score := search(!myTurn, minScore, depth+1, opponentMoves)
-		board.undoMove(move)
-		if score > minScore {
+		bestScore = score
+		if result == prunedMove {
 			continue
 		}
-		minScore = score
 		bestMove = move
-		if minScore <= bestParentScore {
-			return bestMove, minScore // beta-pruning
+		if score <= alpha {
+			continue
+		}
+		alpha = score
+		bound = exactBound
+		g.updatePV(ply, move)
+		if alpha >= beta {
+			g.recordCutoff(ply, depth, move)
+			bound = lowerBound
+			break
+		}
+	}
+	if legal == 0 {
+		if inCheck {
+			// Side to move is checkmated. Mates in fewer moves score
+			// higher for the mating side.
+			return boardMove{}, -mateScore + float32(ply)
 		}
+		return boardMove{}, 0 // Stalemate
 	}
-	return bestMove, minScore
+	g.tt.store(key, depth, scoreToTT(bestScore, ply), bound, bestMove)
+	return bestMove, bestScore
+}
+
+// Outcomes of searching a move other than finding its score
+const (
+	searchedMove = iota
+	illegalMove  // Move leaves the king in check
+	prunedMove   // Move is futile and was not searched
+)
+
+// searchMove makes given move, searches the position it leads to and takes
+// the move back, returning the score of the move. The first move of a
+// position is searched with the full window. Later moves are searched with
+// a null window first, just to show they are no better than the moves
+// before them, and are only searched with the full window again if they
+// turn out better. Futile quiet moves are not searched at all and late
+// quiet moves are searched to a reduced depth first.
+func (g *Game) searchMove(
+	alpha, beta float32, ply, depth int, move boardMove, first,
+	futile bool, reduction int) (float32, int) {
+	g.nodes += 1
+	if !g.isMoveValid(move) {
+		return 0, illegalMove
+	}
+	myTurn := g.MyTurn()
+	g.alterPosition(move)
+	opponentMoves, attacks := g.generateMoves(!myTurn)
+	if g.inCheckSimple(myTurn, attacks) {
+		g.undoMove(move)
+		return 0, illegalMove
+	}
+	// Moves changing material or giving check are never pruned or reduced
+	quiet := !isNoisy(move) && !g.inCheck(g.turn)
+	if futile && quiet {
+		g.undoMove(move)
+		return 0, prunedMove
+	}
+	g.searchPath[ply] = move
+	if !quiet {
+		reduction = 0
+	}
+	var score float32
+	if !first {
+		_, score = g.search(-alpha-nullWindow, -alpha, ply+1,
+			depth-1-reduction, opponentMoves)
+		score = -score
+		if score > alpha && reduction > 0 {
+			_, score = g.search(
+				-alpha-nullWindow, -alpha, ply+1, depth-1, opponentMoves)
+			score = -score
+		}
+	}
+	if first || (score > alpha && score < beta) {
+		_, score = g.search(-beta, -alpha, ply+1, depth-1, opponentMoves)
+		score = -score
+	}
+	g.undoMove(move)
+	return score, searchedMove
+}
+
+// updatePV makes given move followed by the principal variation of the
+// position it leads to the principal variation at given ply
+func (g *Game) updatePV(ply int, move boardMove) {
+	g.pv[ply] = append(g.pv[ply][:0], move)
+	g.pv[ply] = append(g.pv[ply], g.pv[ply+1]...)
+}
+
+// pvMoveFirst puts the move of the previous iteration's principal
+// variation first, as long as the search follows that variation
+func (g *Game) pvMoveFirst(moves []boardMove, ply int) {
+	if !g.followPV {
+		return
+	}
+	g.followPV = false
+	if ply >= len(g.prevPV) {
+		return
+	}
+	for i, move := range moves {
+		if move == g.prevPV[ply] {
+			moveFirst(moves, i)
+			g.followPV = true
+			return
+		}
+	}
+}
+
+// moveFirst moves the move at given index to the front, keeping the order
+// of the others
+func moveFirst(moves []boardMove, i int) {
+	move := moves[i]
+	copy(moves[1:i+1], moves[:i])
+	moves[0] = move
 }
 
-func generateMoves(myTurn bool) ([]boardMove, uint) {
+func (g *Game) generateMoves(myTurn bool) ([]boardMove, uint64) {
 	var pieceMap map[int][]*piece
 	if myTurn {
-		pieceMap = game.myPieces
+		pieceMap = g.myPieces
 	} else {
-		pieceMap = game.otherPieces
+		pieceMap = g.otherPieces
 	}
 	moves := make([]boardMove, 0)
-	var attacks uint
+	var attacks uint64
 	for _, pieces := range pieceMap {
 		for _, piece := range pieces {
 			if piece.captured {
 				continue
 			}
-			pieceMoves, pieceAttacks := piece.moveGenerator(piece)
+			pieceMoves, pieceAttacks := piece.moveGenerator(g, piece)
 			moves = append(moves, pieceMoves...)
 			attacks |= pieceAttacks
 		}
 	}
-	// TODO: come up with some meaningful sorting
+	// Pieces are kept in maps, so sort for the search not to depend on map
+	// iteration order. The search orders moves further with orderMoves.
 	sort.Sort(sortInt(moves))
 	return moves, attacks
 }
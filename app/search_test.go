@@ -0,0 +1,59 @@
+package app
+
+import (
+	"context"
+	"testing"
+)
+
+const kiwipete = "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R " +
+	"w KQkq - 0 1"
+
+func TestSingleThreadDeterministic(t *testing.T) {
+	var moves []UserMove
+	var infos []SearchInfo
+	for i := 0; i < 2; i++ {
+		g, err := NewGameFromFEN(kiwipete, black)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.SetThreads(1)
+		move, info, err := g.MyMoveWithin(
+			context.Background(), SearchLimits{Depth: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		moves = append(moves, move)
+		infos = append(infos, info)
+	}
+	if moves[0] != moves[1] {
+		t.Errorf("searches played %v and %v", moves[0], moves[1])
+	}
+	if infos[0].Nodes != infos[1].Nodes || infos[0].Score != infos[1].Score {
+		t.Errorf("searches differ: %+v and %+v", infos[0], infos[1])
+	}
+}
+
+func TestLazySMP(t *testing.T) {
+	g, err := NewGameFromFEN(kiwipete, black)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := g.Copy()
+	g.SetThreads(4)
+	move, info, err := g.MyMoveWithin(
+		context.Background(), SearchLimits{Depth: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := before.ParseSAN(move.SAN); err != nil {
+		t.Errorf("played illegal move %v: %v", move, err)
+	}
+	if info.Depth != 4 {
+		t.Errorf("searched to depth %d, want 4", info.Depth)
+	}
+	// The main thread searches no more after its last iteration
+	if info.Nodes < g.nodes {
+		t.Errorf("reported %d nodes, fewer than the %d of the main thread",
+			info.Nodes, g.nodes)
+	}
+}
@@ -0,0 +1,146 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// Static Exchange Evaluation predicts the material won by a move, assuming
+// both sides keep capturing on its target square with their least valuable
+// piece for as long as it pays off. Pieces behind sliders which capture are
+// x-ray attackers and join in once the slider has moved.
+
+// losing tells whether a capture or promotion loses material. Moves winning
+// at least the value of the piece left on the target square never do.
+func (g *Game) losing(move boardMove) bool {
+	onSquare := weights[g.board.pieces[move.From].id]
+	if move.PromotedPc > 0 {
+		onSquare = weights[move.PromotedPc]
+	}
+	if materialGain(move) >= onSquare {
+		return false
+	}
+	return g.see(move) < 0
+}
+
+// see returns the material won by the side making given move once the
+// exchange on its target square settles
+func (g *Game) see(move boardMove) int {
+	brd := g.board
+	pc := brd.pieces[move.From]
+	// gains[i] is the material won by the side making the i-th capture if
+	// the exchange stopped right after it
+	var gains [32]int
+	gains[0] = materialGain(move)
+	// Value of the piece standing on the target square, to be captured next
+	onSquare := weights[pc.id]
+	if move.PromotedPc > 0 {
+		onSquare = weights[move.PromotedPc]
+	}
+	occupied := brd.occupied() &^ (1 << uint(move.From))
+	if move.enpassant {
+		occupied &^= 1 << uint(move.captured.square)
+	}
+	attackers := g.attackersTo(move.To, occupied) & occupied
+	side := otherColor(pc.color)
+	count := 1
+	for ; count < len(gains); count++ {
+		sideAttackers := attackers & brd.colors[side]
+		if sideAttackers == 0 {
+			break
+		}
+		pieceType := pawn
+		for ; pieceType >= king; pieceType-- {
+			if sideAttackers&brd.types[pieceType] != 0 {
+				break
+			}
+		}
+		if pieceType == king &&
+			attackers&brd.colors[otherColor(side)] != 0 {
+			// King cannot capture a defended piece
+			break
+		}
+		gains[count] = onSquare - gains[count-1]
+		onSquare = weights[pieceType]
+		attacker := sideAttackers & brd.types[pieceType]
+		occupied &^= 1 << uint(bits.TrailingZeros64(attacker))
+		// Reveal sliders lined up behind the capturing piece
+		attackers |= rookAttacks(move.To, occupied) &
+			(brd.types[rook] | brd.types[queen])
+		attackers |= bishopAttacks(move.To, occupied) &
+			(brd.types[bishop] | brd.types[queen])
+		attackers &= occupied
+		side = otherColor(side)
+	}
+	// Each side may stop capturing when going on would lose material
+	for count--; count > 0; count-- {
+		if -gains[count] < gains[count-1] {
+			gains[count-1] = -gains[count]
+		}
+	}
+	return gains[0]
+}
+
+// attackersTo returns the pieces of either color attacking given square
+// on a board with given occupied squares
+func (g *Game) attackersTo(square int, occupied uint64) uint64 {
+	brd := g.board
+	queens := brd.types[queen]
+	return pawnAttacks[black][square]&brd.colors[white]&brd.types[pawn] |
+		pawnAttacks[white][square]&brd.colors[black]&brd.types[pawn] |
+		knightAttacks[square]&brd.types[knight] |
+		kingAttacks[square]&brd.types[king] |
+		rookAttacks(square, occupied)&(brd.types[rook]|queens) |
+		bishopAttacks(square, occupied)&(brd.types[bishop]|queens)
+}
+
+// StaticExchange returns the material in pawns won by the side making given
+// move once all captures on its target square that pay off are made. A
+// negative value means the move loses material.
+func (g *Game) StaticExchange(move boardMove) (int, error) {
+	pc := g.board.pieces[move.From]
+	if pc == nil {
+		return 0, errors.New("Invalid move")
+	}
+	captured := g.board.pieces[move.To]
+	if captured != nil && captured.color == pc.color {
+		return 0, errors.New("Invalid move")
+	}
+	return g.see(move), nil
+}
+
+// Hanging tells whether the piece on given square, like e4, can be won by
+// the opponent through an exchange of pieces on its square
+func (g *Game) Hanging(square string) (bool, error) {
+	index, err := toIndex(square)
+	if err != nil {
+		return false, err
+	}
+	pc := g.board.pieces[index]
+	if pc == nil {
+		errMsg := fmt.Sprintf("No piece found at %s", square)
+		return false, errors.New(errMsg)
+	}
+	attackers := g.attackersTo(index, g.board.occupied()) &
+		g.board.colors[otherColor(pc.color)]
+	rank, _ := getRankFile(index)
+	for ; attackers != 0; attackers &= attackers - 1 {
+		from := bits.TrailingZeros64(attackers)
+		capture := boardMove{
+			From:         from,
+			To:           index,
+			castlingFrom: -1,
+			castlingTo:   -1,
+			captured:     pc,
+			PromotedPc:   -1,
+		}
+		if g.board.pieces[from].id == pawn && (rank == 1 || rank == 8) {
+			capture.PromotedPc = queen
+		}
+		if g.see(capture) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestStaticExchange(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		san  string
+		gain int
+	}{
+		{"undefended pawn", "4k3/8/8/4p3/8/8/8/4RK2 w - - 0 1", "Rxe5", 1},
+		{
+			"defended pawn", "4k3/3p4/4p3/8/8/8/8/4QK2 w - - 0 1",
+			"Qxe6", -8,
+		},
+		{
+			"pawn takes defended knight", "4k3/8/3p4/4n3/3P4/8/8/4K3 w - - 0 1",
+			"dxe5", 2,
+		},
+		{
+			"rook x-rays through rook", "4r1k1/8/8/4p3/8/8/4R3/4RK2 w - - 0 1",
+			"Rxe5", 1,
+		},
+		{
+			"defender x-rays through rook",
+			"4r1k1/4r3/8/4p3/8/8/4R3/5K2 w - - 0 1",
+			"Rxe5", -4,
+		},
+		{
+			"queen behind bishop", "6k1/8/8/3p4/8/1B6/Q7/6K1 w - - 0 1",
+			"Bxd5", 1,
+		},
+		{
+			"bishop x-rays through queen",
+			"6k1/8/5n2/3p4/8/1Q6/B7/6K1 w - - 0 1", "Qxd5", -5,
+		},
+		{"queen promotion", "3q3k/4P3/8/8/8/8/8/4K3 w - - 0 1", "e8=Q", -1},
+		{"knight promotion", "3q3k/4P3/8/8/8/8/8/4K3 w - - 0 1", "e8=N", -1},
+		{"rook promotion", "3q3k/4P3/8/8/8/8/8/4K3 w - - 0 1", "e8=R", -1},
+		{
+			"capturing promotion", "3q3k/4P3/8/8/8/8/8/4K3 w - - 0 1",
+			"exd8=Q", 17,
+		},
+		{
+			"undefended promotion", "7k/4P3/8/8/8/8/8/4K3 w - - 0 1",
+			"e8=Q", 8,
+		},
+		{
+			"king cannot take defended piece",
+			"3rk3/8/8/8/8/1b6/8/3NK3 b - - 0 1", "Bxd1", 3,
+		},
+		{
+			"king takes undefended piece", "4k3/8/8/8/8/1b6/8/3NK3 b - - 0 1",
+			"Bxd1", 0,
+		},
+	}
+	for _, test := range tests {
+		g, err := NewGameFromFEN(test.fen, black)
+		if err != nil {
+			t.Fatal(err)
+		}
+		move, err := g.ParseSAN(test.san)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		gain, err := g.StaticExchange(move)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if gain != test.gain {
+			t.Errorf("%s: %s gains %d, want %d", test.name, test.san, gain,
+				test.gain)
+		}
+		if losing := g.losing(move); losing != (test.gain < 0) {
+			t.Errorf("%s: losing(%s) = %v", test.name, test.san, losing)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"math"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Bound types of transposition table entries
+const (
+	exactBound = iota + 1 // Score is the value of the position
+	lowerBound            // Value is at least the score
+	upperBound            // Value is at most the score
+)
+
+// DefaultHashSize is the size of transposition tables in megabytes unless
+// set otherwise
+const DefaultHashSize = 16
+
+// maxTTDepth is the deepest search depth an entry can hold
+const maxTTDepth = 127
+
+// ttEntry is the outcome of searching a position. Scores are from the
+// perspective of the side to move.
+type ttEntry struct {
+	score      float32
+	from       int8 // Best move
+	to         int8
+	promotedPc int8
+	depth      int8 // Plies searched below the position
+	bound      uint8
+	generation uint8 // Search in which the entry was stored
+}
+
+// pack fits an entry into a single word, so that it can be read and
+// written atomically
+func (e ttEntry) pack() uint64 {
+	return uint64(math.Float32bits(e.score)) |
+		uint64(e.from)<<32 | uint64(e.to)<<38 |
+		uint64(e.promotedPc+1)<<44 | uint64(e.depth)<<47 |
+		uint64(e.bound)<<54 | uint64(e.generation)<<56
+}
+
+// unpackEntry is the reverse of pack
+func unpackEntry(data uint64) ttEntry {
+	return ttEntry{
+		score:      math.Float32frombits(uint32(data)),
+		from:       int8(data >> 32 & 63),
+		to:         int8(data >> 38 & 63),
+		promotedPc: int8(data>>44&7) - 1,
+		depth:      int8(data >> 47 & 127),
+		bound:      uint8(data >> 54 & 3),
+		generation: uint8(data >> 56),
+	}
+}
+
+// ttSlot holds a packed entry along with the key of its position xor the
+// entry. Threads read and write slots without locking. When writes of two
+// threads to a slot interleave, the key no longer matches and the slot is
+// taken for empty rather than returning an entry of another position.
+type ttSlot struct {
+	check uint64
+	data  uint64
+}
+
+// TranspositionTable remembers the outcome of searching positions, so that
+// positions reached again through a different move order need not be
+// searched again. A table may be shared by games played one after another
+// and by the threads of a search.
+type TranspositionTable struct {
+	slots      []ttSlot
+	generation uint8
+}
+
+// NewTranspositionTable allocates a table taking up to given number of
+// megabytes
+func NewTranspositionTable(megabytes int) *TranspositionTable {
+	if megabytes < 1 {
+		megabytes = 1
+	}
+	size := megabytes << 20 / int(unsafe.Sizeof(ttSlot{}))
+	// Round down to a power of two so that keys map to slots with a mask
+	slots := 1
+	for slots*2 <= size {
+		slots *= 2
+	}
+	return &TranspositionTable{slots: make([]ttSlot, slots)}
+}
+
+// Clear forgets all stored positions, as on starting a new game
+func (tt *TranspositionTable) Clear() {
+	for i := range tt.slots {
+		tt.slots[i] = ttSlot{}
+	}
+	tt.generation = 0
+}
+
+// newSearch ages entries of earlier searches so that they get replaced
+// first
+func (tt *TranspositionTable) newSearch() {
+	tt.generation += 1
+}
+
+func (tt *TranspositionTable) slot(key uint64) *ttSlot {
+	return &tt.slots[key&uint64(len(tt.slots)-1)]
+}
+
+// probe returns the entry stored for the position with given key, if any
+func (tt *TranspositionTable) probe(key uint64) (ttEntry, bool) {
+	slot := tt.slot(key)
+	data := atomic.LoadUint64(&slot.data)
+	if data == 0 || atomic.LoadUint64(&slot.check)^data != key {
+		return ttEntry{}, false
+	}
+	return unpackEntry(data), true
+}
+
+// store records the outcome of searching a position. Entries of the same
+// position or of earlier searches are always replaced, otherwise only by
+// deeper searches.
+func (tt *TranspositionTable) store(
+	key uint64, depth int, score float32, bound int, move boardMove) {
+	slot := tt.slot(key)
+	data := atomic.LoadUint64(&slot.data)
+	old := unpackEntry(data)
+	samePosition := atomic.LoadUint64(&slot.check)^data == key
+	if data != 0 && !samePosition && old.generation == tt.generation &&
+		int(old.depth) > depth {
+		return
+	}
+	if depth > maxTTDepth {
+		depth = maxTTDepth
+	}
+	data = ttEntry{
+		score:      score,
+		from:       int8(move.From),
+		to:         int8(move.To),
+		promotedPc: int8(move.PromotedPc),
+		depth:      int8(depth),
+		bound:      uint8(bound),
+		generation: tt.generation,
+	}.pack()
+	atomic.StoreUint64(&slot.check, key^data)
+	atomic.StoreUint64(&slot.data, data)
+}
+
+// SetTranspositionTable makes the engine use given table, which may be
+// shared with other games
+func (g *Game) SetTranspositionTable(tt *TranspositionTable) {
+	g.tt = tt
+}
+
+// scoreToTT converts a score relative to the root to be stored for a
+// position given number of plies away. Mate scores are made relative to
+// the position, as the same position may be reached at different plies.
+func scoreToTT(score float32, ply int) float32 {
+	if score >= mateScore-maxPly {
+		return score + float32(ply)
+	}
+	if score <= -mateScore+maxPly {
+		return score - float32(ply)
+	}
+	return score
+}
+
+// scoreFromTT converts a stored score back to be relative to the root
+func scoreFromTT(score float32, ply int) float32 {
+	if score >= mateScore-maxPly {
+		return score - float32(ply)
+	}
+	if score <= -mateScore+maxPly {
+		return score + float32(ply)
+	}
+	return score
+}
+
+// hashMoveFirst moves the best move stored for a position to the front of
+// its moves
+func hashMoveFirst(moves []boardMove, entry ttEntry) {
+	for i, move := range moves {
+		if move.From == int(entry.from) && move.To == int(entry.to) &&
+			move.PromotedPc == int(entry.promotedPc) {
+			moveFirst(moves, i)
+			return
+		}
+	}
+}
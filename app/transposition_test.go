@@ -0,0 +1,126 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestTTEntryPacking(t *testing.T) {
+	entries := []ttEntry{
+		{score: 0, from: 0, to: 0, promotedPc: -1, depth: 0,
+			bound: exactBound},
+		{score: -3.25, from: 63, to: 7, promotedPc: -1, depth: 65,
+			bound: upperBound, generation: 200},
+		{score: 1.5, from: 52, to: 60, promotedPc: knight, depth: 1,
+			bound: lowerBound, generation: 1},
+		{score: mateScore - 7, from: 12, to: 28, promotedPc: 0,
+			depth: maxTTDepth, bound: exactBound, generation: 255},
+		{score: -mateScore + 3, from: 1, to: 62, promotedPc: queen,
+			depth: 3, bound: upperBound, generation: 17},
+	}
+	for _, entry := range entries {
+		if got := unpackEntry(entry.pack()); got != entry {
+			t.Errorf("unpackEntry(pack(%+v)) = %+v", entry, got)
+		}
+	}
+}
+
+func TestTTProbe(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	key := uint64(0x9e3779b97f4a7c15)
+	// Same slot, different position
+	other := key + uint64(len(tt.slots))
+	if _, ok := tt.probe(key); ok {
+		t.Fatal("probe of an empty table hit")
+	}
+	move := boardMove{From: 12, To: 28, PromotedPc: -1}
+	tt.store(key, 5, 0.75, lowerBound, move)
+	entry, ok := tt.probe(key)
+	if !ok {
+		t.Fatal("probe missed a stored entry")
+	}
+	if entry.score != 0.75 || entry.depth != 5 ||
+		entry.bound != lowerBound || entry.from != 12 || entry.to != 28 ||
+		entry.promotedPc != -1 {
+		t.Errorf("probe returned %+v", entry)
+	}
+	if _, ok := tt.probe(other); ok {
+		t.Error("probe hit the entry of another position in the slot")
+	}
+	// Interleaved writes of two threads leave a slot whose check does not
+	// match
+	slot := tt.slot(key)
+	slot.check ^= 1
+	if _, ok := tt.probe(key); ok {
+		t.Error("probe hit a torn entry")
+	}
+}
+
+func TestTTReplacement(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	tt.newSearch()
+	key := uint64(0x123456789abcdef)
+	other := key + uint64(len(tt.slots))
+	tt.store(key, 6, 1, exactBound, boardMove{})
+	// Shallower searches of other positions keep the entry
+	tt.store(other, 5, 2, exactBound, boardMove{})
+	if _, ok := tt.probe(key); !ok {
+		t.Error("shallower entry of another position replaced the entry")
+	}
+	// Deeper ones replace it
+	tt.store(other, 7, 2, exactBound, boardMove{})
+	if _, ok := tt.probe(other); !ok {
+		t.Error("deeper entry of another position was not stored")
+	}
+	// Entries of the same position are always replaced
+	tt.store(other, 1, 3, upperBound, boardMove{})
+	if entry, ok := tt.probe(other); !ok || entry.depth != 1 {
+		t.Errorf("shallower entry of the same position not stored: %+v",
+			entry)
+	}
+	tt.store(key, 8, 1, exactBound, boardMove{})
+	// Entries of earlier searches are replaced first
+	tt.newSearch()
+	tt.store(other, 1, 2, exactBound, boardMove{})
+	if _, ok := tt.probe(other); !ok {
+		t.Error("entry of an earlier search was not replaced")
+	}
+	tt.Clear()
+	if _, ok := tt.probe(other); ok {
+		t.Error("probe hit after Clear")
+	}
+}
+
+func TestTTMateScores(t *testing.T) {
+	tests := []struct {
+		storePly, probePly, distance int
+	}{
+		{0, 0, 1},
+		{3, 3, 5},
+		{4, 10, 3},
+		{12, 2, 7},
+	}
+	for _, test := range tests {
+		for _, sign := range []float32{1, -1} {
+			// Mate in distance plies from the stored position, scored
+			// relative to the root
+			mate := sign * (mateScore - float32(test.storePly+test.distance))
+			tt := NewTranspositionTable(1)
+			tt.store(1, 4, scoreToTT(mate, test.storePly), exactBound,
+				boardMove{})
+			entry, ok := tt.probe(1)
+			if !ok {
+				t.Fatal("probe missed a stored entry")
+			}
+			got := scoreFromTT(entry.score, test.probePly)
+			want := sign * (mateScore - float32(test.probePly+test.distance))
+			if got != want {
+				t.Errorf("mate stored at ply %d read at ply %d = %v, want %v",
+					test.storePly, test.probePly, got, want)
+			}
+		}
+	}
+	// Other scores are stored as they are
+	if got := scoreFromTT(scoreToTT(2.5, 7), 3); got != 2.5 {
+		t.Errorf("score 2.5 read back as %v", got)
+	}
+}
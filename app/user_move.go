@@ -4,27 +4,91 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"strconv"
 	"strings"
 )
 
 // UserMove represents move coordinates
 type UserMove struct {
-	From string
-	To   string
+	From      string
+	To        string
+	Promotion string // Piece letter (q, r, b or n) a pawn promotes to
+	SAN       string // Standard Algebraic Notation of moves made by engine
+}
+
+var promotionLetters = map[int]string{
+	queen:  "q",
+	rook:   "r",
+	bishop: "b",
+	knight: "n",
 }
 
 func (m UserMove) String() string {
-	return fmt.Sprintf("%s -> %s", m.From, m.To)
+	coords := fmt.Sprintf("%s -> %s", m.From, m.To)
+	if m.Promotion != "" {
+		coords += "=" + strings.ToUpper(m.Promotion)
+	}
+	if m.SAN == "" {
+		return coords
+	}
+	return fmt.Sprintf("%s (%s)", m.SAN, coords)
+}
+
+// ParseUserMove reads a move in long algebraic notation, as used by UCI,
+// like e2e4 or e7e8q where the last letter is the piece to promote to.
+func ParseUserMove(move string) (UserMove, error) {
+	move = strings.ToLower(strings.TrimSpace(move))
+	if len(move) != 4 && len(move) != 5 {
+		errMsg := fmt.Sprintf("Invalid move '%s'. Expected a move like e2e4",
+			move)
+		return UserMove{}, errors.New(errMsg)
+	}
+	uMove := UserMove{From: move[0:2], To: move[2:4], Promotion: move[4:]}
+	for _, square := range []string{uMove.From, uMove.To} {
+		if _, err := toIndex(square); err != nil {
+			return UserMove{}, err
+		}
+	}
+	if _, err := toPromotedPiece(uMove.Promotion); err != nil {
+		return UserMove{}, err
+	}
+	return uMove, nil
 }
 
-// ToBoardMove converts move coordinates like e2 to board indices like 12.
-func (m UserMove) ToBoardMove() (boardMove, error) {
+// LongAlgebraic returns the move in long algebraic notation like e7e8q
+func (m UserMove) LongAlgebraic() string {
+	return m.From + m.To + m.Promotion
+}
+
+func toPromotedPiece(letter string) (int, error) {
+	if letter == "" {
+		return -1, nil
+	}
+	letter = strings.ToLower(letter)
+	for id, l := range promotionLetters {
+		if l == letter {
+			return id, nil
+		}
+	}
+	errMsg := fmt.Sprintf("Invalid promotion piece: '%s'", letter)
+	return 0, errors.New(errMsg)
+}
+
+// ToBoardMove converts move coordinates like e2 to board indices like 12 of
+// given game. A move in long algebraic notation like e7e8q may be given in
+// From alone.
+func (m UserMove) ToBoardMove(g *Game) (boardMove, error) {
+	if m.To == "" && len(m.From) > 2 {
+		uMove, err := ParseUserMove(m.From)
+		if err != nil {
+			return boardMove{}, err
+		}
+		return uMove.ToBoardMove(g)
+	}
 	fromIndex, err := toIndex(m.From)
 	if err != nil {
 		return boardMove{}, err
 	}
-	pieces := game.board.pieces
+	pieces := g.board.pieces
 	piece := pieces[fromIndex]
 	if piece == nil {
 		err := fmt.Sprintf("Invalid move: No piece found at %s", m.From)
@@ -33,7 +97,31 @@ func (m UserMove) ToBoardMove() (boardMove, error) {
 	toIndex, err := toIndex(m.To)
 	if err != nil {
 		return boardMove{}, err
-	}	
+	}
+	promotedPc, err := toPromotedPiece(m.Promotion)
+	if err != nil {
+		return boardMove{}, err
+	}
+	if promotedPc == -1 && g.IsPromotion(boardMove{From: fromIndex}) {
+		errMsg := fmt.Sprintf(
+			"Choose a piece to promote to, like %s%sq", m.From, m.To)
+		return boardMove{}, errors.New(errMsg)
+	}
+	if piece.id == pawn && pieces[toIndex] == nil &&
+		(toIndex-fromIndex)%8 != 0 {
+		// Diagonal pawn move to an empty square is an en passant capture of
+		// the pawn which has just passed by.
+		bm := boardMove{
+			From:         fromIndex,
+			To:           toIndex,
+			castlingFrom: -1,
+			castlingTo:   -1,
+			captured:     pieces[fromIndex-fromIndex%8+toIndex%8],
+			PromotedPc:   promotedPc,
+			enpassant:    true,
+		}
+		return bm, nil
+	}
 	if piece.id != king || int(math.Abs(float64(toIndex-fromIndex))) != 2 {
 		bm := boardMove{
 			From:         fromIndex,
@@ -41,7 +129,7 @@ func (m UserMove) ToBoardMove() (boardMove, error) {
 			castlingFrom: -1,
 			castlingTo:   -1,
 			captured:     pieces[toIndex],
-			PromotedPc:   -1,
+			PromotedPc:   promotedPc,
 		}
 		return bm, nil
 	}
@@ -71,30 +159,19 @@ func toIndex(m string) (int, error) {
 		errMsg := fmt.Sprintf("'%s' length is not equal to 2", m)
 		return 0, errors.New(errMsg)
 	}
-	// Split move string to file and rank
-	chars := strings.Split(m, "")
-	fileStr, rankStr := chars[0], chars[1]
-	// Convert rank to integer
-	rank, err := strconv.Atoi(rankStr)
-	if err != nil {
-		return 0, err
-	}
-	if rank < 1 || rank > 8 {
+	// Squares are ASCII, so look at bytes rather than runes. 'a' and '1'
+	// are file and rank 0.
+	file, rank := m[0], m[1]
+	if rank < '1' || rank > '8' {
 		errMsg := fmt.Sprintf("Invalid rank: '%s'", m)
 		return 0, errors.New(errMsg)
 	}
-	fileUpper := strings.ToLower(fileStr)
-	// Convert file notation to integer. 'a' will be represented as 1 and so on
-	file := int([]rune(fileUpper)[0] - 97 + 1)
-	if file < 1 || file > 8 {
+	if file >= 'A' && file <= 'H' {
+		file += 'a' - 'A'
+	}
+	if file < 'a' || file > 'h' {
 		errMsg := fmt.Sprintf("Invalid file: '%s'", m)
 		return 0, errors.New(errMsg)
 	}
-	// Compute board index of given coordinate
-	index := 8*(rank-1) + file - 1
-	if index >= 0 && index <= 63 {
-		return index, nil
-	}
-	errMsg := fmt.Sprintf("Invalid coordinate: '%s'", m)
-	return 0, errors.New(errMsg)
+	return 8*int(rank-'1') + int(file-'a'), nil
 }
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestParseUserMove(t *testing.T) {
+	tests := []struct {
+		move string
+		want UserMove
+	}{
+		{"e2e4", UserMove{From: "e2", To: "e4"}},
+		{"a1h8", UserMove{From: "a1", To: "h8"}},
+		{"e7e8q", UserMove{From: "e7", To: "e8", Promotion: "q"}},
+		{" E7D8N ", UserMove{From: "e7", To: "d8", Promotion: "n"}},
+	}
+	for _, test := range tests {
+		got, err := ParseUserMove(test.move)
+		if err != nil {
+			t.Errorf("ParseUserMove(%q) failed: %v", test.move, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseUserMove(%q) = %+v, want %+v", test.move, got,
+				test.want)
+		}
+	}
+}
+
+func TestParseUserMoveErrors(t *testing.T) {
+	moves := []string{
+		"", "e2", "e2e", "e2e4e5", "ée4", "e2é", "éé", "e2e9", "e0e4",
+		"i2e4", "e2i4", "e7e8k", "e7e8x", "e7e8\xc3", "draw", "hint",
+	}
+	for _, move := range moves {
+		if _, err := ParseUserMove(move); err == nil {
+			t.Errorf("ParseUserMove(%q) succeeded", move)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package app
+
+// Zobrist keys identify positions by a 64 bit number. Each piece on each
+// square, black to move, each set of castling rights and each en passant
+// file is assigned a random number, and the key of a position is the XOR of
+// the numbers of its features. Keys are updated as moves are made by XORing
+// out features that no longer hold and XORing in new ones.
+
+var (
+	zobristPieces    [3][7][64]uint64 // Indexed by color, type and square
+	zobristBlackMove uint64
+	zobristCastling  [16]uint64 // Indexed by castling rights
+	zobristEnpassant [8]uint64  // Indexed by file of en passant square
+)
+
+// Seed of the random numbers. Fixed so that keys stay the same across runs.
+const zobristSeed = 1070372
+
+func init() {
+	random := &xorshift{zobristSeed}
+	for _, color := range []int{black, white} {
+		for pieceType := king; pieceType <= pawn; pieceType++ {
+			for square := 0; square < 64; square++ {
+				zobristPieces[color][pieceType][square] = random.next()
+			}
+		}
+	}
+	zobristBlackMove = random.next()
+	for rights := range zobristCastling {
+		zobristCastling[rights] = random.next()
+	}
+	for file := range zobristEnpassant {
+		zobristEnpassant[file] = random.next()
+	}
+}
+
+// Key returns the Zobrist key of the current position. Positions with the
+// same key have the same pieces on the same squares, the same side to move
+// and the same castling and en passant possibilities.
+func (g *Game) Key() uint64 {
+	return g.board.key
+}
+
+// stateKey returns the part of the position key not made up by pieces
+// placed on the board
+func (g *Game) stateKey() uint64 {
+	key := zobristCastling[g.castling]
+	if g.turn == black {
+		key ^= zobristBlackMove
+	}
+	if epSquare := g.capturableEnpassant(); epSquare != -1 {
+		_, file := getRankFile(epSquare)
+		key ^= zobristEnpassant[file-1]
+	}
+	return key
+}
+
+// capturableEnpassant returns the en passant square if a pawn of the side
+// to move can actually capture en passant, or -1
+func (g *Game) capturableEnpassant() int {
+	epSquare := g.enpassantSquare()
+	if epSquare == -1 {
+		return -1
+	}
+	// Pawns able to capture attack the square as seen by a pawn of the
+	// other color standing on it
+	capturers := pawnAttacks[otherColor(g.turn)][epSquare] &
+		g.board.types[pawn] & g.board.colors[g.turn]
+	if capturers == 0 {
+		return -1
+	}
+	return epSquare
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"testing"
+)
+
+// checkKeys walks all move sequences of given number of plies, checking at
+// every node that the incrementally updated key equals the key of the
+// position set up from scratch, also after a null move, and that undoing
+// moves restores the key
+func checkKeys(t *testing.T, g *Game, depth int) {
+	t.Helper()
+	fen := g.FEN()
+	fresh, err := NewGameFromFEN(fen, black)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Key() != fresh.Key() {
+		t.Fatalf("key %#x of %s differs from %#x set up from FEN",
+			g.Key(), fen, fresh.Key())
+	}
+	key := g.Key()
+	if !g.inCheck(g.turn) {
+		g.makeNullMove()
+		nullFEN := g.FEN()
+		fresh, err := NewGameFromFEN(nullFEN, black)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.Key() != fresh.Key() {
+			t.Fatalf("key %#x after null move in %s differs from %#x",
+				g.Key(), fen, fresh.Key())
+		}
+		g.undoNullMove()
+		if g.Key() != key {
+			t.Fatalf("undoing null move in %s changed the key", fen)
+		}
+	}
+	if depth == 0 {
+		return
+	}
+	moves, _ := g.generateMoves(g.MyTurn())
+	for _, move := range moves {
+		if !g.isMoveLegal(move) {
+			continue
+		}
+		g.alterPosition(move)
+		checkKeys(t, g, depth-1)
+		g.undoMove(move)
+		if g.Key() != key {
+			uMove, _ := move.toUserMove()
+			t.Fatalf("undoing %s in %s changed the key",
+				uMove.LongAlgebraic(), fen)
+		}
+	}
+}
+
+func TestZobristKeys(t *testing.T) {
+	fens := []string{
+		StartFEN,
+		// Castling
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R " +
+			"w KQkq - 0 1",
+		// En passant
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+		// Promotions
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+	}
+	depth := 3
+	if testing.Short() {
+		depth = 2
+	}
+	for _, fen := range fens {
+		g, err := NewGameFromFEN(fen, black)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkKeys(t, g, depth)
+	}
+}
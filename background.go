@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// backgroundSearch runs engine searches on their own goroutine, so that
+// protocol handlers keep reading commands while the engine thinks and can
+// stop it
+type backgroundSearch struct {
+	cancel    context.CancelFunc
+	done      chan struct{}
+	abandoned int32 // Set when the result of the search is not wanted
+}
+
+// start runs given search, which is to return soon once its context is
+// done. A search still running is stopped first.
+func (b *backgroundSearch) start(search func(context.Context)) {
+	b.stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	b.cancel, b.done = cancel, done
+	atomic.StoreInt32(&b.abandoned, 0)
+	go func() {
+		defer close(done)
+		search(ctx)
+	}()
+}
+
+// stop makes a running search return and waits for it
+func (b *backgroundSearch) stop() {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.wait()
+}
+
+// wait lets a running search finish on its own
+func (b *backgroundSearch) wait() {
+	if b.done == nil {
+		return
+	}
+	<-b.done
+	b.cancel()
+	b.cancel, b.done = nil, nil
+}
+
+// abandon stops a running search, telling it that its result is not
+// wanted anymore
+func (b *backgroundSearch) abandon() {
+	atomic.StoreInt32(&b.abandoned, 1)
+	b.stop()
+}
+
+// isAbandoned tells a search whether its result is still wanted
+func (b *backgroundSearch) isAbandoned() bool {
+	return atomic.LoadInt32(&b.abandoned) == 1
+}
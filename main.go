@@ -1,16 +1,67 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/SabareeshKumar/heisenberg/app"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
 )
 
 var myTurn = false
 
+var game *app.Game
+
+var pgnFile = flag.String("pgn", "", "file to append finished games to")
+var loadFile = flag.String("load", "", "PGN file with a game to resume")
+var loadPly = flag.Int(
+	"ply", -1, "half move to resume loaded game from (default: last)")
+var threads = flag.Int(
+	"threads", 1, "threads searching for the engine's moves")
+
+// newGame sets up a game from the initial position, or from the game given
+// with -load when there is one.
+func newGame(colorChoice int) (*app.Game, error) {
+	if *loadFile == "" {
+		return app.NewGame(colorChoice), nil
+	}
+	f, err := os.Open(*loadFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	games, err := app.ParsePGN(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(games) == 0 {
+		errMsg := fmt.Sprintf("No games found in %s", *loadFile)
+		return nil, errors.New(errMsg)
+	}
+	ply := *loadPly
+	if ply < 0 {
+		ply = len(games[0].Moves)
+	}
+	return games[0].LoadPly(ply, colorChoice)
+}
+
+// drawReasons describes how a drawn game ended
+var drawReasons = map[int]string{
+	app.Stalemate:            "stalemate",
+	app.Repetition:           "threefold repetition",
+	app.FivefoldRepetition:   "fivefold repetition",
+	app.FiftyMoves:           "the fifty move rule",
+	app.SeventyFiveMoves:     "the seventy five move rule",
+	app.InsufficientMaterial: "insufficient material",
+}
+
 func toggleTurn() bool {
 	myTurn = !myTurn
-	status := app.GameStatus(myTurn)
+	status := game.Status()
 	if status == app.InProgress {
 		return true
 	}
@@ -22,59 +73,50 @@ func toggleTurn() bool {
 		fmt.Println("You lost :(")
 		return false
 	}
-	fmt.Println("Oops. It's a stalemate")
+	if status == app.Stalemate {
+		fmt.Println("Oops. It's a stalemate")
+		return false
+	}
+	fmt.Printf("It's a draw by %s\n", drawReasons[status])
 	return false
 
 }
 
 func play() bool {
-	app.PrintBoard()
+	game.PrintBoard()
 	if myTurn {
-		fmt.Print("Thinking...")
-		move, err := app.MyMove()
+		fmt.Print("Thinking... (press Ctrl-C to move now)")
+		move, info, err := think()
 		if err != nil {
 			fmt.Println(err)
 			return true
 		}
+		fmt.Printf("Evaluated %d board states, %d in quiescence search\n",
+			info.Nodes, info.QNodes)
+		if info.Mate > 0 {
+			fmt.Printf("Mate in %d\n", info.Mate)
+		} else if info.Mate < 0 {
+			fmt.Printf("Engine gets mated in %d\n", -info.Mate)
+		}
 		fmt.Println(move)
 		return toggleTurn()
 	}
-	fmt.Print("\nYour move (Enter 'q' to quit game): ")
+	fmt.Print("\nYour move, like Nf3 or g1f3 (Enter 'q' to quit game): ")
 	var input string
 	fmt.Scan(&input)
 	if strings.ToLower(strings.Trim(input, " ")) == "q" {
 		return false
 	}
-	move := app.UserMove{input, ""}
-	fmt.Scan(&move.To)
-	mv, err := move.ToBoardMove()
+	mv, err := game.ParseSAN(input)
+	if uMove, parseErr := app.ParseUserMove(input); parseErr == nil {
+		// Moves like e2e4 are in long algebraic notation rather than SAN
+		mv, err = uMove.ToBoardMove(game)
+	}
 	if err != nil {
 		fmt.Println(err)
 		return true
 	}
-	if app.IsPromotion(mv) {
-		for {
-			fmt.Println("Please choose a piece type to promote to:")
-			fmt.Println("1. Queen")
-			fmt.Println("2. Rook")
-			fmt.Println("3. Bishop")
-			fmt.Println("4. Knight")
-			fmt.Scan(&mv.PromotedPc)
-			found := true
-			switch mv.PromotedPc {
-			case 1, 2, 3, 4:
-				break
-			default:
-				found = false
-			}
-			if found {
-				// Convert to corresponding piece ID's
-				mv.PromotedPc += 1
-				break
-			}
-		}
-	}
-	err = app.MakeMove(mv)
+	err = game.MakeMove(mv)
 	if err != nil {
 		fmt.Println(err)
 		return true
@@ -82,18 +124,80 @@ func play() bool {
 	return toggleTurn()
 }
 
+// think searches for the engine's move until the search completes or the
+// user interrupts it, in which case the best move found so far is played
+func think() (app.UserMove, app.SearchInfo, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return game.MyMoveWithin(ctx, app.SearchLimits{})
+}
+
+// saveGame prints the game just played in PGN and appends it to the PGN
+// file if one was given.
+func saveGame(colorChoice int) {
+	tags := map[string]string{"White": "Human"}
+	if colorChoice == 1 {
+		tags = map[string]string{"Black": "Human"}
+	}
+	pgn := game.PGN(tags, true)
+	fmt.Println()
+	fmt.Print(pgn)
+	if *pgnFile == "" {
+		return
+	}
+	f, err := os.OpenFile(
+		*pgnFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, pgn)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
 func main() {
-	var colorChoice int
+	flag.Parse()
+	var input string
 	for {
 		fmt.Println("\nChoose a color:\n1. Black\n2. White")
-		fmt.Scanln(&colorChoice)
+		fmt.Scanln(&input)
+		// A GUI is talking to us rather than a human
+		if input == "uci" {
+			uciLoop()
+			return
+		}
+		if input == "xboard" {
+			xboardLoop()
+			return
+		}
+		colorChoice, _ := strconv.Atoi(input)
 		if colorChoice != 1 && colorChoice != 2 {
 			fmt.Println("Invalid choice")
 			continue
 		}
-		myTurn = (colorChoice == 1)
-		app.InitGame(colorChoice)
+		var err error
+		game, err = newGame(colorChoice)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		game.SetThreads(*threads)
+		myTurn = game.MyTurn()
 		for play() {
 		}
+		saveGame(colorChoice)
 	}
 }
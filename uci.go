@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/SabareeshKumar/heisenberg/app"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// uciState tracks the position last sent by the GUI
+type uciState struct {
+	game    *app.Game
+	fen     string   // Position the game started from
+	moves   []string // Moves played from the starting position
+	tt      *app.TranspositionTable
+	pruning app.Pruning // Selective search techniques in use
+	threads int         // Threads searching at once
+	search  backgroundSearch
+}
+
+// uciLoop speaks the Universal Chess Interface over stdin/stdout until the
+// GUI sends 'quit'. It is entered once the GUI has sent the 'uci' command.
+func uciLoop() {
+	state := &uciState{
+		fen:     app.StartFEN,
+		tt:      app.NewTranspositionTable(app.DefaultHashSize),
+		pruning: app.DefaultPruning,
+		threads: *threads,
+	}
+	state.setup()
+	state.identify()
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "stop", "quit", "position", "ucinewgame", "go", "setoption":
+			// Commands which change what is searched, or end the search
+			state.search.stop()
+		}
+		switch fields[0] {
+		case "uci":
+			state.identify()
+		case "isready":
+			fmt.Println("readyok")
+		case "ucinewgame":
+			state.fen = app.StartFEN
+			state.moves = nil
+			state.tt.Clear()
+			state.setup()
+		case "position":
+			state.position(fields[1:])
+		case "go":
+			limits := goLimits(fields[1:])
+			state.search.start(func(ctx context.Context) {
+				state.think(ctx, limits)
+			})
+		case "stop":
+			// Stopped above, which makes the search report its best move
+		case "quit":
+			return
+		case "setoption":
+			state.setOption(fields[1:])
+		case "debug", "register", "ponderhit":
+			// Not supported. Silently ignored as the protocol requires.
+		default:
+			fmt.Printf("info string unknown command: %s\n", fields[0])
+		}
+	}
+	state.search.stop()
+}
+
+func (s *uciState) identify() {
+	fmt.Println("id name heisenberg")
+	fmt.Println("id author Sabareesh Kumar")
+	fmt.Printf("option name Hash type spin default %d min 1 max %d\n",
+		app.DefaultHashSize, maxHashSize)
+	fmt.Printf("option name Threads type spin default %d min 1 max %d\n",
+		*threads, maxThreads)
+	// Selective search techniques may be turned off for testing
+	for _, name := range []string{"NullMove", "Reductions", "Futility"} {
+		fmt.Printf("option name %s type check default true\n", name)
+	}
+	fmt.Println("uciok")
+}
+
+// maxHashSize is the largest transposition table in megabytes a GUI may
+// ask for
+const maxHashSize = 4096
+
+// maxThreads is the largest number of search threads a GUI may ask for
+const maxThreads = 256
+
+// setOption handles 'setoption name <id> [value <x>]'
+func (s *uciState) setOption(args []string) {
+	valueAt := len(args)
+	for i, arg := range args {
+		if arg == "value" {
+			valueAt = i
+			break
+		}
+	}
+	if len(args) < 2 || args[0] != "name" {
+		return
+	}
+	name := strings.Join(args[1:valueAt], " ")
+	value := ""
+	if valueAt < len(args) {
+		value = strings.Join(args[valueAt+1:], " ")
+	}
+	switch strings.ToLower(name) {
+	case "hash":
+		megabytes, err := strconv.Atoi(value)
+		if err != nil || megabytes < 1 || megabytes > maxHashSize {
+			fmt.Printf("info string invalid Hash value: %s\n", value)
+			return
+		}
+		s.tt = app.NewTranspositionTable(megabytes)
+		s.game.SetTranspositionTable(s.tt)
+	case "threads":
+		threads, err := strconv.Atoi(value)
+		if err != nil || threads < 1 || threads > maxThreads {
+			fmt.Printf("info string invalid Threads value: %s\n", value)
+			return
+		}
+		s.threads = threads
+		s.game.SetThreads(s.threads)
+	case "nullmove":
+		s.pruning.NullMove = value == "true"
+		s.game.SetPruning(s.pruning)
+	case "reductions":
+		s.pruning.Reductions = value == "true"
+		s.game.SetPruning(s.pruning)
+	case "futility":
+		s.pruning.Futility = value == "true"
+		s.game.SetPruning(s.pruning)
+	default:
+		fmt.Printf("info string unknown option: %s\n", name)
+	}
+}
+
+// position handles 'position startpos|fen <fen> [moves ...]'
+func (s *uciState) position(args []string) {
+	if len(args) == 0 {
+		return
+	}
+	movesAt := len(args)
+	for i, arg := range args {
+		if arg == "moves" {
+			movesAt = i
+			break
+		}
+	}
+	switch args[0] {
+	case "startpos":
+		s.fen = app.StartFEN
+	case "fen":
+		s.fen = strings.Join(args[1:movesAt], " ")
+	default:
+		fmt.Printf("info string unsupported position: %s\n", args[0])
+		return
+	}
+	s.moves = nil
+	if movesAt < len(args) {
+		s.moves = args[movesAt+1:]
+	}
+	s.setup()
+}
+
+// setup rebuilds the board from the starting position so that the engine
+// plays the side to move.
+func (s *uciState) setup() {
+	game, err := app.NewGameFromFEN(s.fen, 1)
+	if err != nil {
+		fmt.Printf("info string %v\n", err)
+		s.fen = app.StartFEN
+		s.moves = nil
+		game = app.NewGame(1)
+	}
+	game.SetTranspositionTable(s.tt)
+	game.SetPruning(s.pruning)
+	game.SetThreads(s.threads)
+	game.OnIteration(printUciInfo)
+	s.game = game
+	for i, mv := range s.moves {
+		err := playUciMove(game, mv)
+		if err != nil {
+			fmt.Printf("info string %s: %v\n", mv, err)
+			s.moves = s.moves[:i]
+			break
+		}
+	}
+	if !game.MyTurn() {
+		game.SwitchSides()
+	}
+}
+
+// goLimits parses the search limits of 'go [wtime <x>] [btime <x>]
+// [winc <x>] [binc <x>] [movestogo <x>] [movetime <x>] [depth <x>]
+// [nodes <x>] [infinite]'. Times are in milliseconds.
+func goLimits(args []string) app.SearchLimits {
+	var limits app.SearchLimits
+	for i := 0; i < len(args); i++ {
+		if args[i] == "infinite" {
+			limits.Infinite = true
+			continue
+		}
+		if i+1 == len(args) {
+			break
+		}
+		value, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			continue
+		}
+		millis := time.Duration(value) * time.Millisecond
+		switch args[i] {
+		case "wtime":
+			limits.WhiteTime = millis
+		case "btime":
+			limits.BlackTime = millis
+		case "winc":
+			limits.WhiteIncrement = millis
+		case "binc":
+			limits.BlackIncrement = millis
+		case "movestogo":
+			limits.MovesToGo = value
+		case "movetime":
+			limits.MoveTime = millis
+		case "depth":
+			limits.Depth = value
+		case "nodes":
+			limits.Nodes = value
+		default:
+			continue
+		}
+		i++
+	}
+	return limits
+}
+
+// think searches until given limits are reached or the context is done.
+// After an infinite search, the best move is only reported once the GUI
+// sends 'stop'.
+func (s *uciState) think(ctx context.Context, limits app.SearchLimits) {
+	move, _, err := s.game.MyMoveWithin(ctx, limits)
+	if limits.Infinite {
+		<-ctx.Done()
+	}
+	if err != nil {
+		fmt.Println("bestmove 0000")
+		return
+	}
+	fmt.Printf("bestmove %s\n", move.LongAlgebraic())
+	// MyMoveWithin plays the move on the board. Keep history in sync and
+	// hand the other side to the engine in case the GUI sends another 'go'
+	// without a new position.
+	s.moves = append(s.moves, move.LongAlgebraic())
+	s.setup()
+}
+
+// printUciInfo reports a completed iteration of the engine's search
+func printUciInfo(info app.SearchInfo) {
+	millis := info.Duration.Milliseconds()
+	// Too little time has passed to tell the speed right after starting
+	nps := int64(0)
+	if millis > 0 {
+		nps = int64(info.Nodes) * 1000 / millis
+	}
+	score := fmt.Sprintf("cp %d", int(info.Score*100))
+	if info.Mate != 0 {
+		score = fmt.Sprintf("mate %d", info.Mate)
+	}
+	fmt.Printf("info depth %d score %s nodes %d nps %d time %d pv %s\n",
+		info.Depth, score, info.Nodes, nps, millis, pvString(info.PV))
+}
+
+// pvString lists moves of a principal variation in long algebraic notation
+func pvString(pv []app.UserMove) string {
+	moves := make([]string, 0, len(pv))
+	for _, move := range pv {
+		moves = append(moves, move.LongAlgebraic())
+	}
+	return strings.Join(moves, " ")
+}
+
+// playUciMove applies a move in long algebraic notation like e2e4 or e7e8q
+// to given game
+func playUciMove(game *app.Game, mv string) error {
+	uMove, err := app.ParseUserMove(mv)
+	if err != nil {
+		return err
+	}
+	bm, err := uMove.ToBoardMove(game)
+	if err != nil {
+		return err
+	}
+	return game.PlayMove(bm)
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/SabareeshKumar/heisenberg/app"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xboardState tracks the settings chosen by an XBoard/WinBoard GUI
+type xboardState struct {
+	game  *app.Game
+	force bool // Engine only records moves, playing neither side
+	post  bool // Print thinking output
+	depth int  // Search depth set with 'sd', or 0 for the default
+	cores int  // Threads searching at once, set with 'cores'
+	tt    *app.TranspositionTable
+	// Engine thinks in the background so that the GUI can interrupt it
+	search backgroundSearch
+
+	// Time control
+	movesPerSession int           // Moves per time control, 0 for all
+	increment       time.Duration // Time added to the clock per move
+	moveTime        time.Duration // Fixed time per move set with 'st'
+	clock           time.Duration // Time left on the engine's clock
+}
+
+// xboardLoop speaks the Chess Engine Communication Protocol over
+// stdin/stdout until the GUI sends 'quit'. It is entered once the GUI has
+// sent the 'xboard' command.
+func xboardLoop() {
+	state := &xboardState{
+		tt:    app.NewTranspositionTable(app.DefaultHashSize),
+		cores: *threads,
+	}
+	state.newGame()
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		args := fields[1:]
+		switch fields[0] {
+		case "?":
+			// Move now
+			state.search.stop()
+		case "new", "force", "undo", "remove", "setboard", "result",
+			"quit":
+			// Game has moved on without the move being thought of
+			state.search.abandon()
+		case "ping", "otim", "xboard", "accepted", "rejected", "random",
+			"hard", "easy", "computer", "name", "draw":
+			// Answered while thinking
+		default:
+			// Other commands are handled once the engine has moved
+			state.search.wait()
+		}
+		switch fields[0] {
+		case "protover":
+			fmt.Println("feature myname=\"heisenberg\" usermove=1 " +
+				"setboard=1 ping=1 san=0 colors=0 sigint=0 sigterm=0 " +
+				"analyze=0 memory=1 smp=1 done=1")
+		case "new":
+			state.newGame()
+		case "force":
+			state.force = true
+		case "go":
+			state.force = false
+			if !state.game.MyTurn() {
+				state.game.SwitchSides()
+			}
+			state.think()
+		case "usermove":
+			if len(args) > 0 {
+				state.userMove(args[0])
+			}
+		case "setboard":
+			game, err := app.NewGameFromFEN(strings.Join(args, " "), 2)
+			if err != nil {
+				fmt.Printf("tellusererror %v\n", err)
+				continue
+			}
+			state.useGame(game)
+		case "undo":
+			state.game.UndoMove()
+		case "remove":
+			state.game.UndoMove()
+			state.game.UndoMove()
+		case "sd":
+			if len(args) > 0 {
+				state.depth, _ = strconv.Atoi(args[0])
+				state.game.SetMaxDepth(state.depth)
+			}
+		case "memory":
+			if len(args) > 0 {
+				state.setMemory(args[0])
+			}
+		case "cores":
+			if len(args) > 0 {
+				state.setCores(args[0])
+			}
+		case "level":
+			state.setLevel(args)
+		case "st":
+			if len(args) > 0 {
+				seconds, _ := strconv.Atoi(args[0])
+				state.moveTime = time.Duration(seconds) * time.Second
+			}
+		case "time":
+			if len(args) > 0 {
+				centis, _ := strconv.Atoi(args[0])
+				state.clock = time.Duration(centis) * 10 * time.Millisecond
+			}
+		case "otim":
+			// Time left to the opponent is not taken into account.
+		case "post":
+			state.post = true
+		case "nopost":
+			state.post = false
+		case "result":
+			state.force = true
+		case "ping":
+			fmt.Printf("pong %s\n", strings.Join(args, " "))
+		case "quit":
+			return
+		case "xboard", "accepted", "rejected", "random", "hard", "easy",
+			"computer", "name", "?":
+			// Nothing to do
+		case "draw":
+			// Draw offers are declined by ignoring them
+		default:
+			if _, err := app.ParseUserMove(fields[0]); err == nil {
+				// Protocol version 1 GUIs send bare moves
+				state.userMove(fields[0])
+				continue
+			}
+			fmt.Printf("Error (unknown command): %s\n", fields[0])
+		}
+	}
+	state.search.abandon()
+}
+
+func (s *xboardState) newGame() {
+	// Engine plays black unless told otherwise
+	s.depth = 0
+	s.movesPerSession = 0
+	s.increment = 0
+	s.moveTime = 0
+	s.clock = 0
+	s.tt.Clear()
+	s.useGame(app.NewGame(2))
+	s.force = false
+}
+
+// useGame makes the engine play given game with the current settings
+func (s *xboardState) useGame(game *app.Game) {
+	game.SetMaxDepth(s.depth)
+	game.SetTranspositionTable(s.tt)
+	game.SetThreads(s.cores)
+	game.OnIteration(s.printThinking)
+	s.game = game
+}
+
+// xboardMateScore is added to the number of moves to mate in thinking
+// output
+const xboardMateScore = 100000
+
+// printThinking reports a completed iteration of the engine's search if
+// thinking output is on
+func (s *xboardState) printThinking(info app.SearchInfo) {
+	if !s.post {
+		return
+	}
+	centis := info.Duration.Milliseconds() / 10
+	score := int(info.Score * 100)
+	// Mate in N moves is reported as 100000 + N, as GUIs expect
+	if info.Mate > 0 {
+		score = xboardMateScore + info.Mate
+	} else if info.Mate < 0 {
+		score = -xboardMateScore + info.Mate
+	}
+	fmt.Printf("%d %d %d %d %s\n", info.Depth, score, centis, info.Nodes,
+		pvString(info.PV))
+}
+
+// setMemory resizes the transposition table to the number of megabytes
+// the engine may use, as sent with 'memory'
+func (s *xboardState) setMemory(arg string) {
+	megabytes, err := strconv.Atoi(arg)
+	if err != nil || megabytes < 1 {
+		fmt.Printf("Error (invalid memory size): %s\n", arg)
+		return
+	}
+	s.tt = app.NewTranspositionTable(megabytes)
+	s.game.SetTranspositionTable(s.tt)
+}
+
+// setCores sets the number of threads the engine may search with, as sent
+// with 'cores'
+func (s *xboardState) setCores(arg string) {
+	cores, err := strconv.Atoi(arg)
+	if err != nil || cores < 1 {
+		fmt.Printf("Error (invalid number of cores): %s\n", arg)
+		return
+	}
+	s.cores = cores
+	s.game.SetThreads(s.cores)
+}
+
+// setLevel handles 'level MPS BASE INC', where BASE is in minutes or
+// minutes:seconds and INC in seconds
+func (s *xboardState) setLevel(args []string) {
+	if len(args) < 3 {
+		fmt.Println("Error (too few arguments): level")
+		return
+	}
+	moves, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Printf("Error (invalid moves per session): %s\n", args[0])
+		return
+	}
+	base := strings.Replace(args[1], ":", "m", 1) + "s"
+	if !strings.Contains(args[1], ":") {
+		base = args[1] + "m"
+	}
+	baseTime, err := time.ParseDuration(base)
+	if err != nil {
+		fmt.Printf("Error (invalid base time): %s\n", args[1])
+		return
+	}
+	increment, err := time.ParseDuration(args[2] + "s")
+	if err != nil {
+		fmt.Printf("Error (invalid increment): %s\n", args[2])
+		return
+	}
+	s.movesPerSession = moves
+	s.increment = increment
+	s.moveTime = 0
+	s.clock = baseTime
+}
+
+// limits returns the search limits set by the GUI for the engine's move
+func (s *xboardState) limits() app.SearchLimits {
+	limits := app.SearchLimits{Depth: s.depth, MoveTime: s.moveTime}
+	if s.moveTime > 0 || s.clock <= 0 {
+		return limits
+	}
+	if s.game.WhiteToMove() {
+		limits.WhiteTime, limits.WhiteIncrement = s.clock, s.increment
+	} else {
+		limits.BlackTime, limits.BlackIncrement = s.clock, s.increment
+	}
+	if s.movesPerSession > 0 {
+		played := (s.game.MoveNumber() - 1) % s.movesPerSession
+		limits.MovesToGo = s.movesPerSession - played
+	}
+	return limits
+}
+
+func (s *xboardState) userMove(mv string) {
+	err := playUciMove(s.game, mv)
+	if err != nil {
+		fmt.Printf("Illegal move: %s\n", mv)
+		return
+	}
+	// Results are only claimed while the engine plays, not while the GUI
+	// replays a game in force mode. think reports them.
+	if s.force || !s.game.MyTurn() {
+		return
+	}
+	s.think()
+}
+
+// think starts searching for the engine's move in the background
+func (s *xboardState) think() {
+	if s.reportResult() {
+		return
+	}
+	limits := s.limits()
+	s.search.start(func(ctx context.Context) {
+		s.move(ctx, limits)
+	})
+}
+
+// move plays and sends the engine's move once the search has found it,
+// unless the GUI has abandoned the search meanwhile
+func (s *xboardState) move(ctx context.Context, limits app.SearchLimits) {
+	move, _, err := s.game.MyMoveWithin(ctx, limits)
+	if err != nil {
+		fmt.Printf("Error (%v): go\n", err)
+		return
+	}
+	if s.search.isAbandoned() {
+		s.game.UndoMove()
+		return
+	}
+	fmt.Printf("move %s\n", move.LongAlgebraic())
+	s.reportResult()
+}
+
+// reportResult informs the GUI if the game has ended and returns true in
+// that case.
+func (s *xboardState) reportResult() bool {
+	status := s.game.Status()
+	switch status {
+	case app.InProgress:
+		return false
+	case app.Win, app.Lost:
+		// Side to move has been checkmated
+		if s.game.WhiteToMove() {
+			fmt.Println("0-1 {Black mates}")
+		} else {
+			fmt.Println("1-0 {White mates}")
+		}
+	default:
+		fmt.Printf("1/2-1/2 {Draw by %s}\n", drawReasons[status])
+	}
+	return true
+}